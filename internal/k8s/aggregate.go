@@ -0,0 +1,209 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// LogSource is a container whose log is part of aggregated logs
+type LogSource struct {
+	Pod       string
+	Container string
+}
+
+func (s LogSource) String() string {
+	return s.Pod + "/" + s.Container
+}
+
+// logAggregator streams the logs of the containers of the pods seen by a pod informer
+type logAggregator struct {
+	ctx        context.Context
+	client     kubernetes.Interface
+	namespace  string
+	podPattern *regexp.Regexp
+	options    LogOptions
+	onLine     func(source LogSource, line string)
+	onSources  func(sources []LogSource)
+	onError    func(source LogSource, err error)
+
+	mutex   sync.Mutex
+	streams map[LogSource]*logStream
+	// when the last stream of a container ended, a restarted container continues from there
+	ended map[LogSource]time.Time
+	wait  sync.WaitGroup
+}
+
+type logStream struct {
+	cancel context.CancelFunc
+}
+
+// StreamAggregatedLogs follows the logs of all containers of the pods in namespace that match the label
+// selector and podPattern (nil matches every pod), like stern. Pods that start matching are picked up
+// and deleted pods are dropped, onSources is called with the streamed containers whenever they change.
+// It returns when ctx is canceled (nil) or the pods can't be watched.
+func StreamAggregatedLogs(ctx context.Context, c kubernetes.Interface, namespace string, selector string, podPattern *regexp.Regexp,
+	options LogOptions, onLine func(source LogSource, line string), onSources func(sources []LogSource),
+	onError func(source LogSource, err error)) error {
+	if _, err := labels.Parse(selector); err != nil {
+		return fmt.Errorf("invalid label selector %q: %w", selector, err)
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(c, podResyncPeriod, informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(listOptions *metav1.ListOptions) {
+			listOptions.LabelSelector = selector
+		}))
+	informer := factory.Core().V1().Pods().Informer()
+
+	a := &logAggregator{ctx: ctx, client: c, namespace: namespace, podPattern: podPattern, options: options, onLine: onLine,
+		onSources: onSources, onError: onError, streams: make(map[LogSource]*logStream), ended: make(map[LogSource]time.Time)}
+	defer a.wait.Wait()
+
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    a.update,
+		UpdateFunc: func(_, obj interface{}) { a.update(obj) },
+		DeleteFunc: a.remove,
+	})
+	if err != nil {
+		return err
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+
+	syncCtx, cancel := context.WithTimeout(ctx, podSyncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), informer.HasSynced) {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return newAPIError(fmt.Sprintf("failed to sync pods in namespace %s", namespace), context.DeadlineExceeded)
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// stream the containers of a pod that have a log
+func (a *logAggregator) update(obj interface{}) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || (a.podPattern != nil && !a.podPattern.MatchString(pod.Name)) {
+		return
+	}
+	for _, container := range pod.Spec.Containers {
+		status, ok := GetContainerStatus(pod, container.Name)
+		if !ok {
+			continue
+		}
+		source := LogSource{Pod: pod.Name, Container: container.Name}
+		// waiting containers have no log yet, exited containers are shown once
+		if status.State.Running != nil || (status.State.Terminated != nil && !a.hasEnded(source)) {
+			a.start(source)
+		}
+	}
+}
+
+// stop the streams of a deleted pod
+func (a *logAggregator) remove(obj interface{}) {
+	// deleted objects can arrive as tombstones
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	a.mutex.Lock()
+	for source, stream := range a.streams {
+		if source.Pod == pod.Name {
+			stream.cancel()
+			delete(a.streams, source)
+		}
+	}
+	for source := range a.ended {
+		if source.Pod == pod.Name {
+			delete(a.ended, source)
+		}
+	}
+	sources := a.getSources()
+	a.mutex.Unlock()
+	a.onSources(sources)
+}
+
+func (a *logAggregator) hasEnded(source LogSource) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	_, ended := a.ended[source]
+	return ended
+}
+
+func (a *logAggregator) start(source LogSource) {
+	a.mutex.Lock()
+	if _, streaming := a.streams[source]; streaming || a.ctx.Err() != nil {
+		a.mutex.Unlock()
+		return
+	}
+	options := a.options
+	if ended, ok := a.ended[source]; ok {
+		// the container restarted, skip the lines already shown
+		options = LogOptions{SinceTime: ended, Timestamps: a.options.Timestamps}
+	}
+	ctx, cancel := context.WithCancel(a.ctx)
+	stream := &logStream{cancel: cancel}
+	a.streams[source] = stream
+	sources := a.getSources()
+	a.wait.Add(1)
+	a.mutex.Unlock()
+	a.onSources(sources)
+
+	go func() {
+		defer a.wait.Done()
+		defer cancel()
+		err := StreamPodLogs(ctx, a.client, a.namespace, source.Pod, source.Container, options, func(line string) {
+			a.onLine(source, line)
+		})
+
+		a.mutex.Lock()
+		// a deleted pod or a new stream of the container replaced this one
+		if a.streams[source] != stream {
+			a.mutex.Unlock()
+			return
+		}
+		delete(a.streams, source)
+		a.ended[source] = time.Now()
+		sources := a.getSources()
+		a.mutex.Unlock()
+
+		if err != nil && ctx.Err() == nil {
+			a.onError(source, err)
+		}
+		a.onSources(sources)
+	}()
+}
+
+// get the streamed containers sorted by pod and container, call with the mutex held
+func (a *logAggregator) getSources() []LogSource {
+	sources := make([]LogSource, 0, len(a.streams))
+	for source := range a.streams {
+		sources = append(sources, source)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].Pod != sources[j].Pod {
+			return sources[i].Pod < sources[j].Pod
+		}
+		return sources[i].Container < sources[j].Container
+	})
+	return sources
+}
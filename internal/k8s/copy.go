@@ -0,0 +1,381 @@
+package k8s
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// symlinks followed to resolve a link of a copy, like the limit of the kernel
+const maxCopyLinkDepth = 40
+
+// CopyProgress is the progress of a copy between a container and local files
+type CopyProgress struct {
+	Files int
+	Bytes int64
+	// bytes of all files, 0 when unknown (downloads)
+	TotalBytes int64
+}
+
+// CopyResult is a finished copy, entries that were not copied because they would be written
+// outside of the destination are listed in Skipped
+type CopyResult struct {
+	CopyProgress
+	Skipped []string
+}
+
+// CopyFromContainer downloads a file or directory of a container into localDirectory like kubectl cp,
+// with tar running in the container. Entries with absolute or ".." paths and symlinks pointing outside
+// of localDirectory are skipped. progress is called while the files are written.
+func CopyFromContainer(ctx context.Context, client kubernetes.Interface, config rest.Config, podName string,
+	containerName string, podNamespace string, remotePath string, localDirectory string,
+	progress func(CopyProgress)) (CopyResult, error) {
+	remotePath = path.Clean(remotePath)
+	if !path.IsAbs(remotePath) {
+		return CopyResult{}, fmt.Errorf("container path %q is not absolute", remotePath)
+	}
+	// archive the last element of the path, so the download is named after it
+	command := []string{"tar", "cf", "-", "-C", path.Dir(remotePath), path.Base(remotePath)}
+	if remotePath == "/" {
+		command = []string{"tar", "cf", "-", "-C", "/", "."}
+	}
+
+	reader, writer := io.Pipe()
+	type extractResult struct {
+		result CopyResult
+		err    error
+	}
+	extracted := make(chan extractResult, 1)
+	go func() {
+		result, err := extractTar(reader, localDirectory, progress)
+		// a failed extraction ends the download
+		reader.CloseWithError(err)
+		extracted <- extractResult{result, err}
+	}()
+
+	stderr := &bytes.Buffer{}
+	err := streamCopyCommand(ctx, client, config, podName, containerName, podNamespace, command, nil, writer, stderr)
+	writer.CloseWithError(err)
+	extract := <-extracted
+	// the extraction fails with the error of the download too
+	if extract.err != nil && extract.err != err {
+		return extract.result, newAPIError("error writing "+localDirectory, extract.err)
+	}
+	if err != nil {
+		return extract.result, getCopyError(fmt.Sprintf("error downloading %s", remotePath), containerName, err, stderr)
+	}
+	return extract.result, nil
+}
+
+// CopyToContainer uploads a local file or directory into remoteDirectory of a container like kubectl cp,
+// with tar running in the container. Symlinks are uploaded as symlinks. progress is called while the
+// files are read.
+func CopyToContainer(ctx context.Context, client kubernetes.Interface, config rest.Config, podName string,
+	containerName string, podNamespace string, localPath string, remoteDirectory string,
+	progress func(CopyProgress)) (CopyProgress, error) {
+	remoteDirectory = path.Clean(remoteDirectory)
+	if !path.IsAbs(remoteDirectory) {
+		return CopyProgress{}, fmt.Errorf("container path %q is not absolute", remoteDirectory)
+	}
+	total, err := getCopySize(localPath)
+	if err != nil {
+		return CopyProgress{}, newAPIError("error reading "+localPath, err)
+	}
+
+	reader, writer := io.Pipe()
+	archived := make(chan CopyProgress, 1)
+	var archiveErr error
+	go func() {
+		written, err := writeTar(writer, localPath, total, progress)
+		archiveErr = err
+		writer.CloseWithError(err)
+		archived <- written
+	}()
+
+	stderr := &bytes.Buffer{}
+	command := []string{"tar", "xmf", "-", "-C", remoteDirectory}
+	err = streamCopyCommand(ctx, client, config, podName, containerName, podNamespace, command, reader, io.Discard, stderr)
+	// stop archiving when tar ended early
+	reader.CloseWithError(io.ErrClosedPipe)
+	written := <-archived
+	if archiveErr != nil && !errors.Is(archiveErr, io.ErrClosedPipe) {
+		return written, newAPIError("error reading "+localPath, archiveErr)
+	}
+	if err != nil {
+		return written, getCopyError(fmt.Sprintf("error uploading to %s", remoteDirectory), containerName, err, stderr)
+	}
+	return written, nil
+}
+
+// run a command of a copy in a container
+func streamCopyCommand(ctx context.Context, client kubernetes.Interface, config rest.Config, podName string,
+	containerName string, podNamespace string, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	exec, err := newExecutor(client, config, podName, containerName, podNamespace,
+		&corev1.PodExecOptions{Command: command, Stdin: stdin != nil, Stdout: true, Stderr: true})
+	if err != nil {
+		return err
+	}
+	err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{Stdin: stdin, Stdout: stdout, Stderr: stderr})
+	// a command that finished as ctx ended succeeded
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// explain a failed copy with the output of tar, a missing tar gets an error of its own
+func getCopyError(message string, containerName string, err error, stderr *bytes.Buffer) error {
+	output := strings.TrimSpace(stderr.String())
+	exitCode, exited := GetExitCode(err)
+	if (exited && exitCode == 127) || strings.Contains(err.Error(), "executable file not found") ||
+		strings.Contains(output, "tar: not found") || strings.Contains(output, "tar: command not found") {
+		return newAPIError(fmt.Sprintf("tar is not installed in container %s, copying files needs it in the image", containerName), err)
+	}
+	if output != "" {
+		return newAPIError(message+": "+output, err)
+	}
+	return newAPIError(message, err)
+}
+
+// extract a tar stream into destination, skipping entries that would be written outside of it
+func extractTar(r io.Reader, destination string, progress func(CopyProgress)) (CopyResult, error) {
+	var result CopyResult
+	destination, err := filepath.Abs(destination)
+	if err != nil {
+		return result, err
+	}
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+		target, ok := getCopyTargetPath(destination, header.Name)
+		// symlinks copied before could lead a path elsewhere, entries are never written through them
+		if !ok || hasSymlinkParent(destination, target) {
+			result.Skipped = append(result.Skipped, header.Name)
+			continue
+		}
+		if target == destination {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return result, err
+		}
+		// an existing symlink is replaced, never written through
+		if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if err := os.Remove(target); err != nil {
+				return result, err
+			}
+		}
+
+		mode := header.FileInfo().Mode().Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode|0700); err != nil {
+				return result, err
+			}
+		case tar.TypeReg:
+			file, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+			if err != nil {
+				return result, err
+			}
+			_, err = io.Copy(&copyProgressWriter{w: file, progress: progress, result: &result.CopyProgress}, tarReader)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return result, err
+			}
+			result.Files++
+			if progress != nil {
+				progress(result.CopyProgress)
+			}
+		case tar.TypeSymlink:
+			if !isCopyLinkInside(destination, target, header.Linkname) {
+				result.Skipped = append(result.Skipped, header.Name+" -> "+header.Linkname)
+				continue
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return result, err
+			}
+		default:
+			// hard links, devices and fifos are not copied
+			result.Skipped = append(result.Skipped, header.Name)
+		}
+	}
+}
+
+// the local path of a tar entry, false for absolute paths and paths leaving destination
+func getCopyTargetPath(destination string, name string) (string, bool) {
+	if path.IsAbs(name) || filepath.IsAbs(name) {
+		return "", false
+	}
+	target := filepath.Join(destination, filepath.FromSlash(name))
+	return target, isPathInside(destination, target)
+}
+
+// whether a symlink at target pointing to linkname stays inside destination, resolved on disk
+// through the symlinks extracted before, e.g. l2 -> l1/../x leaves it when l1 -> .
+func isCopyLinkInside(destination string, target string, linkname string) bool {
+	_, ok := resolveCopyLink(destination, filepath.Dir(target), linkname, 0)
+	return ok
+}
+
+// resolve linkname from directory like the file system does, false when a step leaves destination.
+// Below a path element that doesn't exist yet ".." can't be resolved and is refused.
+func resolveCopyLink(destination string, directory string, linkname string, depth int) (string, bool) {
+	if path.IsAbs(linkname) || filepath.IsAbs(linkname) || depth > maxCopyLinkDepth {
+		return "", false
+	}
+	current, missing := directory, false
+	for _, element := range strings.Split(filepath.ToSlash(linkname), "/") {
+		if element == "" || element == "." {
+			continue
+		}
+		next := filepath.Join(current, element)
+		if element == ".." {
+			if missing {
+				return "", false
+			}
+		} else if !missing {
+			info, err := os.Lstat(next)
+			if err != nil {
+				missing = true
+			} else if info.Mode()&os.ModeSymlink != 0 {
+				link, err := os.Readlink(next)
+				if err != nil {
+					return "", false
+				}
+				var ok bool
+				if next, ok = resolveCopyLink(destination, current, link, depth+1); !ok {
+					return "", false
+				}
+			}
+		}
+		current = next
+		if !isPathInside(destination, current) {
+			return "", false
+		}
+	}
+	return current, true
+}
+
+// whether a directory between destination and target is a symlink
+func hasSymlinkParent(destination string, target string) bool {
+	for directory := filepath.Dir(target); directory != destination && isPathInside(destination, directory); directory = filepath.Dir(directory) {
+		if info, err := os.Lstat(directory); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func isPathInside(directory string, target string) bool {
+	relative, err := filepath.Rel(directory, target)
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+// bytes of the regular files of a local file or directory
+func getCopySize(localPath string) (int64, error) {
+	var size int64
+	err := filepath.Walk(localPath, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// write a local file or directory as a tar stream, named by its last element
+func writeTar(w io.Writer, localPath string, total int64, progress func(CopyProgress)) (CopyProgress, error) {
+	written := CopyProgress{TotalBytes: total}
+	localPath = filepath.Clean(localPath)
+	parent := filepath.Dir(localPath)
+	tarWriter := tar.NewWriter(w)
+	err := filepath.Walk(localPath, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(parent, file)
+		if err != nil {
+			return err
+		}
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		} else if !info.Mode().IsRegular() && !info.IsDir() {
+			// sockets, devices and fifos are not copied
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relative)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := io.Copy(&copyProgressWriter{w: tarWriter, progress: progress, result: &written}, f); err != nil {
+			return err
+		}
+		written.Files++
+		if progress != nil {
+			progress(written)
+		}
+		return nil
+	})
+	if err != nil {
+		return written, err
+	}
+	return written, tarWriter.Close()
+}
+
+// copyProgressWriter adds the bytes written to a copy and reports its progress
+type copyProgressWriter struct {
+	w        io.Writer
+	progress func(CopyProgress)
+	result   *CopyProgress
+}
+
+func (c *copyProgressWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.result.Bytes += int64(n)
+	if c.progress != nil {
+		c.progress(*c.result)
+	}
+	return n, err
+}
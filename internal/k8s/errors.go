@@ -0,0 +1,105 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+// ErrorClass groups errors by what the user can do to fix them
+type ErrorClass int
+
+const (
+	ErrorUnknown ErrorClass = iota
+	// kubeconfig missing, invalid or context not found
+	ErrorConfig
+	// credentials missing or expired (401)
+	ErrorAuth
+	// user lacks RBAC permission (403)
+	ErrorRBAC
+	// API server unreachable, connection refused or timed out
+	ErrorNetwork
+	// resource no longer exists (404)
+	ErrorNotFound
+	// API server rate limited the request (429)
+	ErrorThrottled
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorConfig:
+		return "Configuration"
+	case ErrorAuth:
+		return "Authentication"
+	case ErrorRBAC:
+		return "Permission"
+	case ErrorNetwork:
+		return "Network"
+	case ErrorNotFound:
+		return "Not Found"
+	case ErrorThrottled:
+		return "Throttled"
+	}
+	return "Unknown"
+}
+
+// APIError is returned by the k8s functions, it keeps the original error for errors.Is/As
+type APIError struct {
+	Class   ErrorClass
+	Message string
+	Err     error
+}
+
+func (e *APIError) Error() string {
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// wrap err with message and its classification
+func newAPIError(message string, err error) *APIError {
+	return &APIError{Class: ClassifyError(err), Message: message, Err: err}
+}
+
+// ClassifyError returns the class of err, using the class of a wrapped APIError when present
+func ClassifyError(err error) ErrorClass {
+	var apiError *APIError
+	switch {
+	case err == nil:
+		return ErrorUnknown
+	case errors.As(err, &apiError) && apiError.Class != ErrorUnknown:
+		return apiError.Class
+	case apierrors.IsUnauthorized(err):
+		return ErrorAuth
+	case apierrors.IsForbidden(err):
+		return ErrorRBAC
+	case apierrors.IsNotFound(err), apierrors.IsGone(err):
+		return ErrorNotFound
+	case apierrors.IsTooManyRequests(err):
+		return ErrorThrottled
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err), apierrors.IsServiceUnavailable(err),
+		errors.Is(err, context.DeadlineExceeded), utilnet.IsConnectionRefused(err), utilnet.IsConnectionReset(err),
+		utilnet.IsProbableEOF(err):
+		return ErrorNetwork
+	}
+
+	var netError net.Error
+	if errors.As(err, &netError) {
+		return ErrorNetwork
+	}
+	return ErrorUnknown
+}
+
+// get the delay the API server asked for before retrying, zero when none was given
+func GetRetryDelay(err error) time.Duration {
+	if seconds, ok := apierrors.SuggestsClientDelay(err); ok {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
@@ -0,0 +1,138 @@
+package k8s
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// name of the manifest in a log bundle
+const logManifestName = "manifest.json"
+
+// LogManifest describes the logs of a pod in a log bundle
+type LogManifest struct {
+	Pod        string            `json:"pod"`
+	Namespace  string            `json:"namespace"`
+	Node       string            `json:"node,omitempty"`
+	Phase      string            `json:"phase,omitempty"`
+	ExportedAt time.Time         `json:"exportedAt"`
+	Files      []LogManifestFile `json:"files"`
+}
+
+// LogManifestFile is a container log in a log bundle, a log that couldn't be read has an error and no file
+type LogManifestFile struct {
+	Name          string `json:"name,omitempty"`
+	Container     string `json:"container"`
+	ContainerType string `json:"containerType"`
+	Image         string `json:"image,omitempty"`
+	Previous      bool   `json:"previous"`
+	RestartCount  int32  `json:"restartCount"`
+	Bytes         int64  `json:"bytes"`
+	Error         string `json:"error,omitempty"`
+}
+
+// directories of the container logs in a log bundle
+var logBundleDirectories = map[ContainerType]string{
+	ContainerTypeInit:      "init-containers/",
+	ContainerTypeRegular:   "containers/",
+	ContainerTypeEphemeral: "ephemeral-containers/",
+}
+
+// GetLogBundleName returns the file name of the log bundle of a pod exported at time now
+func GetLogBundleName(podName string, now time.Time) string {
+	return podName + "-logs-" + now.Format("20060102-150405") + ".zip"
+}
+
+// ExportPodLogs writes a zip of the current and previous logs of all containers of a pod, init and
+// ephemeral containers included, with a manifest.json listing the files. Logs that can't be read are
+// recorded in the manifest with their error, the export only fails when the zip can't be written.
+func ExportPodLogs(ctx context.Context, c kubernetes.Interface, pod *corev1.Pod, w io.Writer, now time.Time) (LogManifest, error) {
+	manifest := LogManifest{Pod: pod.Name, Namespace: pod.Namespace, Node: pod.Spec.NodeName, Phase: string(pod.Status.Phase),
+		ExportedAt: now.UTC(), Files: []LogManifestFile{}}
+
+	archive := zip.NewWriter(w)
+	for _, container := range GetPodContainers(pod) {
+		status, _ := GetContainerStatus(pod, container.Name)
+		file := LogManifestFile{Container: container.Name, ContainerType: string(container.Type), Image: container.Image,
+			RestartCount: container.RestartCount}
+		directory := logBundleDirectories[container.Type]
+
+		// previous logs exist once the container restarted
+		if status.RestartCount > 0 || status.LastTerminationState.Terminated != nil {
+			previous := file
+			previous.Previous = true
+			previous.Name = directory + container.Name + ".previous.log"
+			if err := exportContainerLog(ctx, c, pod, archive, &previous, now); err != nil {
+				return manifest, err
+			}
+			manifest.Files = append(manifest.Files, previous)
+		}
+
+		file.Name = directory + container.Name + ".log"
+		if err := exportContainerLog(ctx, c, pod, archive, &file, now); err != nil {
+			return manifest, err
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	manifestFile, err := archive.CreateHeader(&zip.FileHeader{Name: logManifestName, Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return manifest, newAPIError("error writing log bundle", err)
+	}
+	encoder := json.NewEncoder(manifestFile)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return manifest, newAPIError("error writing log bundle", err)
+	}
+	if err := archive.Close(); err != nil {
+		return manifest, newAPIError("error writing log bundle", err)
+	}
+	return manifest, nil
+}
+
+// copy a container log into the zip, errors of the log stream are recorded in the manifest file
+// and only errors writing the zip are returned
+func exportContainerLog(ctx context.Context, c kubernetes.Interface, pod *corev1.Pod, archive *zip.Writer,
+	file *LogManifestFile, now time.Time) error {
+	podLogOptions := &corev1.PodLogOptions{Container: file.Container, Previous: file.Previous}
+	podStream, err := c.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, podLogOptions).Stream(ctx)
+	if err != nil {
+		file.Name, file.Error = "", err.Error()
+		return ctx.Err()
+	}
+	defer podStream.Close()
+
+	logFile, err := archive.CreateHeader(&zip.FileHeader{Name: file.Name, Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return newAPIError("error writing log bundle", err)
+	}
+	counter := &countingWriter{w: logFile}
+	if _, err := io.Copy(counter, podStream); err != nil {
+		if counter.err != nil {
+			return newAPIError("error writing log bundle", counter.err)
+		}
+		// the part read so far stays in the bundle
+		file.Error = err.Error()
+	}
+	file.Bytes = counter.n
+	return ctx.Err()
+}
+
+// countingWriter counts the bytes written and keeps the write error apart from read errors of io.Copy
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
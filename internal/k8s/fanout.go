@@ -0,0 +1,185 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// FanOutTarget is a container a fan-out command runs in
+type FanOutTarget struct {
+	Pod       string
+	Container string
+}
+
+func (t FanOutTarget) String() string {
+	return t.Pod + "/" + t.Container
+}
+
+// FanOutResult is the result of a fan-out command in one container
+type FanOutResult struct {
+	Target FanOutTarget
+	Output string
+	// exit code of the command, -1 when it failed without one (see Err)
+	ExitCode int
+	Err      error
+	Duration time.Duration
+}
+
+// FanOutGroup is the containers whose command ended with the same output and exit code
+type FanOutGroup struct {
+	Output   string
+	ExitCode int
+	// error of commands that failed without an exit code
+	Error   string
+	Targets []FanOutTarget
+}
+
+// ListFanOutPods returns the running pods in namespace matching the label selector and podPattern
+// (nil matches every pod), sorted by name
+func ListFanOutPods(ctx context.Context, c kubernetes.Interface, namespace string, selector string,
+	podPattern *regexp.Regexp) ([]corev1.Pod, error) {
+	if _, err := labels.Parse(selector); err != nil {
+		return nil, fmt.Errorf("invalid label selector %q: %w", selector, err)
+	}
+	podList, err := c.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, newAPIError("error listing pods", err)
+	}
+	var pods []corev1.Pod
+	for _, pod := range podList.Items {
+		if pod.Status.Phase == corev1.PodRunning && (podPattern == nil || podPattern.MatchString(pod.Name)) {
+			pods = append(pods, pod)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	return pods, nil
+}
+
+// GetFanOutContainers returns the container names of pods in order of appearance, for picking the container
+func GetFanOutContainers(pods []corev1.Pod) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, pod := range pods {
+		for _, podContainer := range pod.Spec.Containers {
+			if !seen[podContainer.Name] {
+				seen[podContainer.Name] = true
+				names = append(names, podContainer.Name)
+			}
+		}
+	}
+	return names
+}
+
+// GetFanOutTargets returns the container of every pod, pods without it are returned as missing
+func GetFanOutTargets(pods []corev1.Pod, containerName string) ([]FanOutTarget, []string) {
+	var targets []FanOutTarget
+	var missing []string
+	for _, pod := range pods {
+		found := false
+		for _, podContainer := range pod.Spec.Containers {
+			found = found || podContainer.Name == containerName
+		}
+		if found {
+			targets = append(targets, FanOutTarget{Pod: pod.Name, Container: containerName})
+		} else {
+			missing = append(missing, pod.Name)
+		}
+	}
+	return targets, missing
+}
+
+// FanOutExec runs command in every target with ExecCmd, at most parallelism at once and each with
+// timeout (0 for none). onResult is called with the index of the target as commands finish, one call
+// at a time. The results are returned in the order of the targets when all are done or ctx is canceled.
+func FanOutExec(ctx context.Context, client kubernetes.Interface, config rest.Config, namespace string,
+	targets []FanOutTarget, command string, parallelism int, timeout time.Duration,
+	onResult func(index int, result FanOutResult)) []FanOutResult {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	results := make([]FanOutResult, len(targets))
+	slots := make(chan struct{}, parallelism)
+	var resultMutex sync.Mutex
+	var wait sync.WaitGroup
+
+	for i, target := range targets {
+		started := false
+		select {
+		case slots <- struct{}{}:
+			started = ctx.Err() == nil
+			if !started {
+				<-slots
+			}
+		case <-ctx.Done():
+		}
+		wait.Add(1)
+		go func(i int, target FanOutTarget, started bool) {
+			defer wait.Done()
+			result := FanOutResult{Target: target, ExitCode: -1, Err: ctx.Err()}
+			if started {
+				result = runFanOutCommand(ctx, client, config, namespace, target, command, timeout)
+				<-slots
+			}
+
+			resultMutex.Lock()
+			defer resultMutex.Unlock()
+			results[i] = result
+			if onResult != nil {
+				onResult(i, result)
+			}
+		}(i, target, started)
+	}
+	wait.Wait()
+	return results
+}
+
+func runFanOutCommand(ctx context.Context, client kubernetes.Interface, config rest.Config, namespace string,
+	target FanOutTarget, command string, timeout time.Duration) FanOutResult {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	output, err := ExecCmd(ctx, client, config, target.Pod, target.Container, namespace, command, nil)
+	result := FanOutResult{Target: target, Output: output, Duration: time.Since(start)}
+	if exitCode, ok := GetExitCode(err); ok {
+		result.ExitCode = exitCode
+	} else if err != nil {
+		result.ExitCode, result.Err = -1, err
+	}
+	return result
+}
+
+// GroupFanOutResults groups the results with identical output and exit code, the largest group first.
+// The smaller groups are the outliers.
+func GroupFanOutResults(results []FanOutResult) []FanOutGroup {
+	var groups []FanOutGroup
+	indexes := make(map[string]int)
+	for _, result := range results {
+		group := FanOutGroup{Output: result.Output, ExitCode: result.ExitCode}
+		if result.Err != nil {
+			group.Error = result.Err.Error()
+		}
+		key := fmt.Sprintf("%d\x00%s\x00%s", group.ExitCode, group.Error, group.Output)
+		i, ok := indexes[key]
+		if !ok {
+			i = len(groups)
+			indexes[key] = i
+			groups = append(groups, group)
+		}
+		groups[i].Targets = append(groups[i].Targets, result.Target)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return len(groups[i].Targets) > len(groups[j].Targets) })
+	return groups
+}
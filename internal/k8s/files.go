@@ -0,0 +1,326 @@
+package k8s
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// ErrFilesUnavailable is returned when a container has no shell or ls to list its files, e.g. distroless images
+var ErrFilesUnavailable = errors.New("the container has no shell or ls (e.g. a distroless image)")
+
+type FileType string
+
+const (
+	FileRegular   FileType = "file"
+	FileDirectory FileType = "directory"
+	FileSymlink   FileType = "symlink"
+	FileOther     FileType = "other"
+)
+
+// FileEntry is a file in a container directory
+type FileEntry struct {
+	Name string
+	Path string
+	Type FileType
+	Size int64
+	// permissions like ls, e.g. drwxr-xr-x
+	Mode string
+	// zero when ls doesn't show it
+	ModTime    time.Time
+	LinkTarget string
+	// the symlink points to a directory
+	LinkDirectory bool
+}
+
+// IsDirectory is true for directories and symlinks to directories
+func (e FileEntry) IsDirectory() bool {
+	return e.Type == FileDirectory || e.LinkDirectory
+}
+
+// lists a directory with stat (busybox and coreutils), or ls -la without stat; the first line tells which.
+// The names of symlinks to directories follow a "links" line.
+const listDirectoryScript = `cd -- "$1" || exit 2
+if command -v stat >/dev/null 2>&1; then
+	echo stat
+	stat -c '%f|%s|%Y|%n|%N' -- .* * 2>/dev/null
+else
+	echo ls
+	ls -la || exit
+fi
+echo links
+for name in .* *; do
+	[ -L "$name" ] && [ -d "$name" ] && printf '%s\n' "$name"
+done
+exit 0`
+
+// a line of ls -la: mode, links, owner, group, size (or device numbers), date and name
+var lsLinePattern = regexp.MustCompile(`^([-a-zA-Z?][-rwxsStTl?]{9}[.+@]?)\s+\d+\s+\S+\s+\S+\s+(\d+|\d+,\s*\d+)\s+(\w{3}\s+\d+\s+[\d:]+)\s(.*)$`)
+
+// ListDirectory lists a directory of a container, directories first and then by name.
+// It returns ErrFilesUnavailable (wrapped) when the container can't list files.
+func ListDirectory(ctx context.Context, client kubernetes.Interface, config rest.Config, podName string,
+	containerName string, podNamespace string, directory string) ([]FileEntry, error) {
+	directory = path.Clean(directory)
+	if !path.IsAbs(directory) {
+		return nil, fmt.Errorf("container path %q is not absolute", directory)
+	}
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	err := streamCopyCommand(ctx, client, config, podName, containerName, podNamespace,
+		[]string{"sh", "-c", listDirectoryScript, "sh", directory}, nil, stdout, stderr)
+	if err != nil {
+		return nil, getFilesError("error listing "+directory, err, stderr)
+	}
+	return parseDirectoryListing(stdout.String(), directory, time.Now()), nil
+}
+
+// parse the output of listDirectoryScript, the year of ls dates without one is taken from now
+func parseDirectoryListing(output string, directory string, now time.Time) []FileEntry {
+	var entries []FileEntry
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	if !scanner.Scan() {
+		return nil
+	}
+	format := scanner.Text()
+	linkDirectories := map[string]bool{}
+	for scanner.Scan() {
+		if format == "links" {
+			linkDirectories[scanner.Text()] = true
+			continue
+		}
+		if scanner.Text() == "links" {
+			format = "links"
+			continue
+		}
+		var entry FileEntry
+		var ok bool
+		if format == "stat" {
+			entry, ok = parseStatLine(scanner.Text())
+		} else {
+			entry, ok = parseLsLine(scanner.Text(), now)
+		}
+		if !ok || entry.Name == "." || entry.Name == ".." {
+			continue
+		}
+		entry.Path = path.Join(directory, entry.Name)
+		entries = append(entries, entry)
+	}
+	for i := range entries {
+		entries[i].LinkDirectory = entries[i].Type == FileSymlink && linkDirectories[entries[i].Name]
+	}
+	sortFileEntries(entries)
+	return entries
+}
+
+// a line of stat -c '%f|%s|%Y|%n|%N': raw mode in hex, size, modification time, name and quoted name with link target
+func parseStatLine(line string) (FileEntry, bool) {
+	fields := strings.SplitN(line, "|", 4)
+	if len(fields) != 4 {
+		return FileEntry{}, false
+	}
+	rawMode, err := strconv.ParseUint(fields[0], 16, 32)
+	if err != nil {
+		return FileEntry{}, false
+	}
+	size, _ := strconv.ParseInt(fields[1], 10, 64)
+	seconds, _ := strconv.ParseInt(fields[2], 10, 64)
+
+	// the name can contain "|", the quoted name of %N follows it
+	name, quoted := fields[3], ""
+	if i := strings.LastIndex(fields[3], "|'"); i >= 0 {
+		name, quoted = fields[3][:i], fields[3][i+1:]
+	} else if i := strings.LastIndex(fields[3], "|"); i >= 0 {
+		name, quoted = fields[3][:i], fields[3][i+1:]
+	}
+	entry := FileEntry{Name: name, Size: size, ModTime: time.Unix(seconds, 0), Mode: formatFileMode(uint32(rawMode))}
+	entry.Type = getFileType(entry.Mode)
+	if entry.Type == FileSymlink {
+		if _, target, found := strings.Cut(quoted, " -> "); found {
+			entry.LinkTarget = strings.Trim(target, `'"`)
+		}
+	}
+	return entry, true
+}
+
+// a line of ls -la, busybox and coreutils use the same columns
+func parseLsLine(line string, now time.Time) (FileEntry, bool) {
+	match := lsLinePattern.FindStringSubmatch(line)
+	if match == nil {
+		return FileEntry{}, false
+	}
+	entry := FileEntry{Name: match[4], Mode: match[1][:10]}
+	entry.Type = getFileType(entry.Mode)
+	entry.Size, _ = strconv.ParseInt(match[2], 10, 64)
+	if entry.Type == FileSymlink {
+		if name, target, found := strings.Cut(entry.Name, " -> "); found {
+			entry.Name, entry.LinkTarget = name, target
+		}
+	}
+	date := strings.Join(strings.Fields(match[3]), " ")
+	if modTime, err := time.ParseInLocation("Jan 2 2006", date, time.Local); err == nil {
+		entry.ModTime = modTime
+	} else if modTime, err := time.ParseInLocation("Jan 2 15:04 2006", date+" "+strconv.Itoa(now.Year()), time.Local); err == nil {
+		// dates without a year are within the last 6 months
+		if modTime.After(now.AddDate(0, 0, 1)) {
+			modTime = modTime.AddDate(-1, 0, 0)
+		}
+		entry.ModTime = modTime
+	}
+	return entry, true
+}
+
+// ls like permissions of a raw st_mode
+func formatFileMode(rawMode uint32) string {
+	var mode strings.Builder
+	switch rawMode & 0xf000 {
+	case 0x4000:
+		mode.WriteByte('d')
+	case 0xa000:
+		mode.WriteByte('l')
+	case 0x8000:
+		mode.WriteByte('-')
+	case 0x2000:
+		mode.WriteByte('c')
+	case 0x6000:
+		mode.WriteByte('b')
+	case 0x1000:
+		mode.WriteByte('p')
+	case 0xc000:
+		mode.WriteByte('s')
+	default:
+		mode.WriteByte('?')
+	}
+	for i, char := range "rwxrwxrwx" {
+		if rawMode&(1<<uint(8-i)) != 0 {
+			mode.WriteRune(char)
+		} else {
+			mode.WriteByte('-')
+		}
+	}
+	return mode.String()
+}
+
+func getFileType(mode string) FileType {
+	switch mode[0] {
+	case 'd':
+		return FileDirectory
+	case 'l':
+		return FileSymlink
+	case '-':
+		return FileRegular
+	}
+	return FileOther
+}
+
+// directories first, then by name
+func sortFileEntries(entries []FileEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].IsDirectory() != entries[j].IsDirectory() {
+			return entries[i].IsDirectory()
+		}
+		return entries[i].Name < entries[j].Name
+	})
+}
+
+// errFileLimit ends reading a file at its limit
+var errFileLimit = errors.New("file limit reached")
+
+// ReadFile returns the first limit bytes of a container file, truncated is true for longer files
+func ReadFile(ctx context.Context, client kubernetes.Interface, config rest.Config, podName string,
+	containerName string, podNamespace string, filePath string, limit int64) ([]byte, bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	content := &limitedBuffer{limit: limit, cancel: cancel}
+	stderr := &bytes.Buffer{}
+	err := streamCopyCommand(ctx, client, config, podName, containerName, podNamespace,
+		[]string{"cat", "--", filePath}, nil, content, stderr)
+	if content.truncated {
+		return content.Bytes(), true, nil
+	}
+	if err != nil {
+		return nil, false, getFilesError("error reading "+filePath, err, stderr)
+	}
+	return content.Bytes(), false, nil
+}
+
+// DownloadFile writes a container file to w and returns its size, it doesn't need tar like CopyFromContainer
+func DownloadFile(ctx context.Context, client kubernetes.Interface, config rest.Config, podName string,
+	containerName string, podNamespace string, filePath string, w io.Writer) (int64, error) {
+	counter := &countingWriter{w: w}
+	stderr := &bytes.Buffer{}
+	err := streamCopyCommand(ctx, client, config, podName, containerName, podNamespace,
+		[]string{"cat", "--", filePath}, nil, counter, stderr)
+	if counter.err != nil {
+		return counter.n, newAPIError("error writing "+path.Base(filePath), counter.err)
+	}
+	if err != nil {
+		return counter.n, getFilesError("error downloading "+filePath, err, stderr)
+	}
+	return counter.n, nil
+}
+
+// explain a failed file command with its output, a missing shell or ls wraps ErrFilesUnavailable
+func getFilesError(message string, err error, stderr *bytes.Buffer) error {
+	output := strings.TrimSpace(stderr.String())
+	exitCode, exited := GetExitCode(err)
+	if (exited && exitCode == 127) || strings.Contains(err.Error(), "executable file not found") ||
+		strings.Contains(err.Error(), "no such file or directory: unknown") {
+		return newAPIError(message, fmt.Errorf("%w: %v", ErrFilesUnavailable, err))
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if output != "" {
+		// the first line is enough, e.g. "cd: can't cd to /root: Permission denied"
+		output, _, _ = strings.Cut(output, "\n")
+		return newAPIError(message+": "+output, err)
+	}
+	return newAPIError(message, err)
+}
+
+// limitedBuffer keeps the first limit bytes written and cancels the command writing more
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int64
+	truncated bool
+	cancel    context.CancelFunc
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - int64(b.Len()); int64(len(p)) > remaining {
+		b.Buffer.Write(p[:remaining])
+		b.truncated = true
+		b.cancel()
+		return int(remaining), errFileLimit
+	}
+	return b.Buffer.Write(p)
+}
+
+// IsTextFile reports whether content looks like text, binary files have NUL bytes or many control characters
+func IsTextFile(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	if bytes.IndexByte(content, 0) >= 0 {
+		return false
+	}
+	printable := 0
+	for _, b := range content {
+		if b >= 0x20 || b == '\n' || b == '\r' || b == '\t' || b == 0x1b {
+			printable++
+		}
+	}
+	return printable*10 >= len(content)*9
+}
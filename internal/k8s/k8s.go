@@ -3,16 +3,11 @@ package k8s
 import (
 	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"log"
-	"math"
-	"path/filepath"
-	"strconv"
 	"strings"
-	"sync"
-	"time"
 
 	"github.com/michaeljsaenz/kview/internal/utils"
 	corev1 "k8s.io/api/core/v1"
@@ -25,135 +20,124 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/remotecommand"
-	"k8s.io/client-go/util/homedir"
 	"sigs.k8s.io/yaml"
 )
 
-// TODO parse cluster context name to drop unnecessary text
-func GetCurrentContext() string {
+// path to the kubeconfig file, set with the --kubeconfig flag
+var kubeconfig string
+
+func init() {
+	flag.StringVar(&kubeconfig, "kubeconfig", "", "(optional) absolute path to the kubeconfig file, defaults to $KUBECONFIG or ~/.kube/config")
+}
+
+// get current context name, see FormatContextName for a short display name
+func GetCurrentContext() (string, error) {
 	// get current context
 	clientConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		clientcmd.NewDefaultClientConfigLoadingRules(),
+		getLoadingRules(),
 		&clientcmd.ConfigOverrides{
 			CurrentContext: "",
 		}).RawConfig()
 	if err != nil {
-		panic(err.Error())
+		return "", &APIError{Class: ErrorConfig, Message: "failed to load kubeconfig", Err: err}
 	}
-	return clientConfig.CurrentContext
+	return clientConfig.CurrentContext, nil
+}
+
+func GetClientSet() (*kubernetes.Clientset, *rest.Config, error) {
+	return GetClientSetForContext("")
 }
 
-func GetClientSet() (*kubernetes.Clientset, *rest.Config) {
+// create clientset for the named context, empty name uses the current context
+func GetClientSetForContext(contextName string) (*kubernetes.Clientset, *rest.Config, error) {
 	// https://github.com/kubernetes/client-go/blob/master/examples/out-of-cluster-client-configuration/main.go
-	var kubeconfig *string
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
+	if !flag.Parsed() {
+		flag.Parse()
 	}
-	flag.Parse()
 
-	// use the current context in kubeconfig
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	// merge kubeconfig files with the standard loading rules
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		getLoadingRules(),
+		&clientcmd.ConfigOverrides{
+			CurrentContext: contextName,
+		}).ClientConfig()
 	if err != nil {
-		//TODO raise this error to UI
-		log.Fatal("kubeconfig error: ", err)
+		return nil, nil, &APIError{Class: ErrorConfig, Message: "kubeconfig error", Err: err}
 	}
 
 	// create the clientset
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		//TODO raise this error to UI
-		log.Fatal("clientset error: ", err)
+		return nil, nil, &APIError{Class: ErrorConfig, Message: "clientset error", Err: err}
 	}
 
-	return clientset, config
+	return clientset, config, nil
 
 }
 
-// get pod names with provided namespace
-func GetPodDataWithNamespace(c kubernetes.Clientset, namespace string) (podData []string) {
-	pods, err := c.CoreV1().Pods(namespace).List(context.TODO(), v1.ListOptions{})
+// get pod list rows with provided namespace
+func GetPodSummariesWithNamespace(ctx context.Context, c kubernetes.Clientset, namespace string) (podSummaries []PodSummary, err error) {
+	pods, err := c.CoreV1().Pods(namespace).List(ctx, v1.ListOptions{})
 	if err != nil {
-		podData = append(podData, fmt.Sprint(err))
-	} else {
-		for _, pod := range pods.Items {
-			podData = append(podData, pod.Name)
-		}
+		return nil, newAPIError("failed to get pods", err)
+	}
+	for i := range pods.Items {
+		podSummaries = append(podSummaries, GetPodSummary(&pods.Items[i]))
 	}
-	return podData
+	return podSummaries, nil
 
 }
 
 // get namespaces
-func GetNamespaces(c kubernetes.Clientset) (namespaceList []string) {
+func GetNamespaces(ctx context.Context, c kubernetes.Clientset) (namespaceList []string, err error) {
 	// retrieve the list of namespaces
-	namespaces, err := c.CoreV1().Namespaces().List(context.TODO(), v1.ListOptions{})
+	namespaces, err := c.CoreV1().Namespaces().List(ctx, v1.ListOptions{})
 	if err != nil {
-		fmt.Printf("failed to get namespaces: %v", err)
-		namespaceList = append(namespaceList, fmt.Sprint(err))
-	} else {
-		for _, namespace := range namespaces.Items {
-			namespaceList = append(namespaceList, namespace.Name)
-		}
+		return nil, newAPIError("failed to get namespaces", err)
+	}
+	for _, namespace := range namespaces.Items {
+		namespaceList = append(namespaceList, namespace.Name)
 	}
-	return namespaceList
+	return namespaceList, nil
 
 }
 
-func GetPodDetail(c kubernetes.Clientset, selectedPod string, podNamespace string) (string, string, string, []string) {
-	pod, err := c.CoreV1().Pods(podNamespace).Get(context.TODO(), selectedPod, v1.GetOptions{})
+// get a pod from the API server, prefer a PodCache for the selected pod
+func GetPod(ctx context.Context, c kubernetes.Interface, podNamespace string, podName string) (*corev1.Pod, error) {
+	pod, err := c.CoreV1().Pods(podNamespace).Get(ctx, podName, v1.GetOptions{})
 	if err != nil {
-		fmt.Printf("failed to get pod detail: %v", err)
-	}
-
-	podCreationTime := pod.GetCreationTimestamp()
-	age := time.Since(podCreationTime.Time).Round(time.Second)
-	podAge := age.String()
-	if int(math.Trunc(age.Hours())) >= 24 {
-		ageInDays := int(math.Trunc(age.Hours())) / 24
-		podAge = strconv.Itoa(ageInDays) + "d"
-	}
-
-	var containers []string
-	for _, container := range pod.Spec.Containers {
-		containers = append(containers, container.Name)
+		return nil, newAPIError("failed to get pod", err)
 	}
-	return string(pod.Status.Phase), podAge, pod.Spec.NodeName, containers
+	return pod, nil
 }
 
-func GetPodLabels(c kubernetes.Clientset, selectedPod string, podNamespace string) string {
-	pod, err := c.CoreV1().Pods(podNamespace).Get(context.TODO(), selectedPod, v1.GetOptions{})
-	if err != nil {
-		fmt.Printf("failed to get pod labels: %v", err)
-	}
+// get status, age, node and containers of a pod, init and ephemeral containers included
+func GetPodDetail(pod *corev1.Pod) (string, string, string, []PodContainer) {
+	podAge := GetAge(pod.GetCreationTimestamp().Time)
+	return string(pod.Status.Phase), podAge, pod.Spec.NodeName, GetPodContainers(pod)
+}
 
+func GetPodLabels(pod *corev1.Pod) string {
 	return utils.ConvertMapToString(pod.Labels)
 }
 
-func GetPodAnnotations(c kubernetes.Clientset, selectedPod string, podNamespace string) string {
-	pod, err := c.CoreV1().Pods(podNamespace).Get(context.TODO(), selectedPod, v1.GetOptions{})
-	if err != nil {
-		fmt.Printf("failed to get pod annotations: %v", err)
-	}
-
+func GetPodAnnotations(pod *corev1.Pod) string {
 	return utils.ConvertMapToString(pod.Annotations)
 }
 
-func GetPodEvents(c kubernetes.Clientset, selectedPod string, podNamespace string) (podEvents []string) {
-	events, _ := c.CoreV1().Events(podNamespace).List(context.TODO(), v1.ListOptions{FieldSelector: fmt.Sprintf("involvedObject.name=%s", selectedPod), TypeMeta: v1.TypeMeta{Kind: "Pod"}})
+func GetPodEvents(ctx context.Context, c kubernetes.Clientset, selectedPod string, podNamespace string) (podEvents []string, err error) {
+	events, err := c.CoreV1().Events(podNamespace).List(ctx, v1.ListOptions{FieldSelector: fmt.Sprintf("involvedObject.name=%s", selectedPod), TypeMeta: v1.TypeMeta{Kind: "Pod"}})
+	if err != nil {
+		return nil, newAPIError("failed to get pod events", err)
+	}
 	for _, item := range events.Items {
 		podEvents = append(podEvents, item.FirstTimestamp.String()+" "+item.Message)
 	}
-	return podEvents
+	return podEvents, nil
 }
 
-func GetPodVolumes(c kubernetes.Clientset, selectedPod string, podNamespace string) (podVolumes string, err error) {
-	pod, err := c.CoreV1().Pods(podNamespace).Get(context.TODO(), selectedPod, v1.GetOptions{})
+func GetPodVolumes(pod *corev1.Pod) (podVolumes string, err error) {
 	var podVolumeSlice []string
-	if err != nil {
-		return "", fmt.Errorf("failed to get pod: %v", err)
-	}
 
 	// check if the pod has containers
 	if len(pod.Spec.Containers) == 0 {
@@ -177,60 +161,33 @@ func GetPodVolumes(c kubernetes.Clientset, selectedPod string, podNamespace stri
 	return strings.Join(podVolumeSlice, ""), nil
 }
 
-func GetPodLogs(c kubernetes.Clientset, podNamespace string, selectedPod string, containerName string) (podLog string) {
-	const (
-		logTailLines = 1000
-	)
-	podLogReq := c.CoreV1().Pods(podNamespace).GetLogs(selectedPod, &corev1.PodLogOptions{Container: containerName,
-		TailLines: utils.CreateInt64(logTailLines)})
-
-	podLog = podLogStreamToString(podLogReq)
+func GetPodLogs(ctx context.Context, c kubernetes.Clientset, podNamespace string, selectedPod string, containerName string,
+	options LogOptions) (podLog string, err error) {
+	podLogReq := c.CoreV1().Pods(podNamespace).GetLogs(selectedPod, options.podLogOptions(containerName, false))
 
-	return podLog
+	return podLogStreamToString(ctx, podLogReq)
 }
 
-func podLogStreamToString(podLogReq *rest.Request) (podLog string) {
-	podStream, err := podLogReq.Stream(context.TODO())
+func podLogStreamToString(ctx context.Context, podLogReq *rest.Request) (podLog string, err error) {
+	podStream, err := podLogReq.Stream(ctx)
 	if err != nil {
-		return fmt.Sprintf("error opening pod log stream, %v", err)
+		return "", newAPIError("error opening pod log stream", err)
 	}
 	defer podStream.Close()
 
 	buf := new(bytes.Buffer)
 	_, err = io.Copy(buf, podStream)
 	if err != nil {
-		return "error copying pod log stream to buf"
+		return "", newAPIError("error copying pod log stream to buf", err)
 	}
 	podLog = buf.String()
 
-	return podLog
-}
-
-func GetPodNamespace(c kubernetes.Clientset, podName string) (podNamespace string) {
-	podNameWithNamespace := make(map[string]string)
-	pods, err := c.CoreV1().Pods("").List(context.TODO(), v1.ListOptions{})
-	if err != nil {
-		panic(err.Error())
-	}
-	var podsItemsList []string
-	for _, pod := range pods.Items {
-		podsItemsList = append(podsItemsList, pod.Name, pod.Namespace)
-	}
-	for i := 0; i < len(podsItemsList); i += 2 {
-		podNameWithNamespace[podsItemsList[i]] = podsItemsList[i+1]
-	}
-	podNamespace = podNameWithNamespace[podName]
-
-	return podNamespace
+	return podLog, nil
 }
 
-func GetPodYaml(c kubernetes.Clientset, podNamespace string, podName string) (string, error) {
-	pod, err := c.CoreV1().Pods(podNamespace).Get(context.TODO(), podName, v1.GetOptions{})
-	if err != nil {
-		return "", fmt.Errorf("error getting pod: %v", err)
-	}
-
-	// clear unnecessary fields
+func GetPodYaml(pod *corev1.Pod) (string, error) {
+	// clear unnecessary fields on a copy, pod may be shared with a cache
+	pod = pod.DeepCopy()
 	pod.ObjectMeta.ManagedFields = nil
 	pod.ObjectMeta.GenerateName = ""
 	pod.Status = corev1.PodStatus{}
@@ -256,74 +213,56 @@ func GetClientInterface(c kubernetes.Clientset) kubernetes.Interface {
 	return &c
 }
 
-func ExecCmd(client kubernetes.Interface, config rest.Config, podName string, containerName string,
+// ExecCmd runs command with sh -c in a container and returns its output, stdout followed by stderr.
+// The output so far is returned with an error too, the timeout is set by ctx.
+func ExecCmd(ctx context.Context, client kubernetes.Interface, config rest.Config, podName string, containerName string,
 	podNamespace string, command string, stdin io.Reader) (string, error) {
-	// command based on the input
-	cmd := []string{"sh", "-c", command}
-
-	option := &corev1.PodExecOptions{
-		Command:   cmd,
-		Stdin:     stdin != nil,
-		Stdout:    true,
-		Stderr:    true,
-		TTY:       false,
-		Container: containerName,
-	}
-
-	req := client.CoreV1().RESTClient().Post().Resource("pods").Name(podName).
-		Namespace(podNamespace).SubResource("exec")
-
-	req.VersionedParams(option, scheme.ParameterCodec)
-
-	exec, err := remotecommand.NewSPDYExecutor(&config, "POST", req.URL())
-	if err != nil {
-		return "", err
-	}
-
-	// context with a timeout of 5 seconds
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
 	// create buffers to capture the command output
 	stdoutBuffer := &bytes.Buffer{}
 	stderrBuffer := &bytes.Buffer{}
 
-	// wait group to synchronize the command execution
-	var wg sync.WaitGroup
-	// add the command execution to wait group
-	wg.Add(1)
-
-	// execute the command in a separate goroutine
-	go executeCommand(exec, ctx, stdin, stdoutBuffer, stderrBuffer, &wg)
-
-	// wait for the command execution to complete
-	wg.Wait()
+	err := StreamExecCmd(ctx, client, config, podName, containerName, podNamespace, command, stdin, stdoutBuffer, stderrBuffer)
 
 	// combine stdout/stderr into string
 	output := stdoutBuffer.String() + stderrBuffer.String()
 
-	return output, nil
+	return output, err
 }
 
-func executeCommand(exec remotecommand.Executor, ctx context.Context, stdin io.Reader,
-	stdoutBuffer, stderrBuffer *bytes.Buffer, wg *sync.WaitGroup) {
-	defer wg.Done()
+// StreamExecCmd runs command with sh -c in a container, writing its stdout and stderr while it runs.
+// It returns when the command exits or ctx is done. A non-zero exit code is returned as an error
+// (see GetExitCode), as are timeouts and cancellation (errors.Is context.DeadlineExceeded/Canceled).
+func StreamExecCmd(ctx context.Context, client kubernetes.Interface, config rest.Config, podName string, containerName string,
+	podNamespace string, command string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	// command based on the input
+	cmd := []string{"sh", "-c", command}
+
+	exec, err := newExecutor(client, config, podName, containerName, podNamespace,
+		&corev1.PodExecOptions{Command: cmd, Stdin: stdin != nil, Stdout: true, Stderr: true})
+	if err != nil {
+		return err
+	}
 
-	// execute the command and capture stdout/stderr buffers
-	err := exec.StreamWithContext(ctx, remotecommand.StreamOptions{
+	err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{
 		Stdin:  stdin,
-		Stdout: stdoutBuffer,
-		Stderr: stderrBuffer,
-		Tty:    false,
+		Stdout: stdout,
+		Stderr: stderr,
 	})
+	return getExecError(ctx, err)
+}
 
-	if err != nil {
-		errorMsg := fmt.Sprintf("%v", err)
-		if errorMsg == "context deadline exceeded" {
-			errorMsg = "\nINFO: command took too long to complete, try another command."
-			fmt.Fprintln(stderrBuffer, errorMsg)
-		} else {
-			fmt.Fprintln(stderrBuffer, "\n"+errorMsg)
-		}
+// explain a failed command, a command that succeeded as ctx ended still succeeded
+func getExecError(ctx context.Context, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return newAPIError("command timed out", ctx.Err())
+	case ctx.Err() != nil:
+		return newAPIError("command stopped", ctx.Err())
+	}
+	if exitCode, ok := GetExitCode(err); ok {
+		return newAPIError(fmt.Sprintf("command exited with code %d", exitCode), err)
 	}
+	return newAPIError("error executing command", err)
 }
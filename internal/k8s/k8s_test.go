@@ -1,9 +1,27 @@
 package k8s
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	utilexec "k8s.io/client-go/util/exec"
 )
 
 func TestGetCurrentContext(t *testing.T) {
@@ -13,9 +31,515 @@ func TestGetCurrentContext(t *testing.T) {
 			CurrentContext: "",
 		}).RawConfig()
 	expectedCurrentContext := testClientConfig.CurrentContext
-	currentContext := GetCurrentContext()
+	currentContext, _ := GetCurrentContext()
 
 	if expectedCurrentContext != currentContext {
 		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", currentContext, expectedCurrentContext)
 	}
 }
+
+func TestGetKubeconfigPaths(t *testing.T) {
+	t.Setenv("KUBECONFIG", "/tmp/a:/tmp/b:/tmp/a")
+
+	paths := GetKubeconfigPaths()
+	expectedPaths := []string{"/tmp/a", "/tmp/b"}
+	if strings.Join(paths, ",") != strings.Join(expectedPaths, ",") {
+		t.Errorf("Did not get expected result. Got '%v', wanted '%v'", paths, expectedPaths)
+	}
+}
+
+func TestFormatContextName(t *testing.T) {
+	testCases := []struct {
+		contextName string
+		server      string
+		expected    string
+	}{
+		{"arn:aws:eks:us-west-2:123456789012:cluster/prod", "", "us-west-2/prod"},
+		{"arn:aws-us-gov:eks:us-gov-west-1:123456789012:cluster/gov", "", "us-gov-west-1/gov"},
+		{"admin@staging.eu-west-1.eksctl.io", "", "eu-west-1/staging"},
+		{"gke_my-project_us-central1-a_my-cluster", "", "us-central1-a/my-cluster"},
+		{"aks-prod", "https://aks-prod-dns-1a2b3c.hcp.eastus.azmk8s.io:443", "eastus/aks-prod"},
+		{"kind-kind", "https://127.0.0.1:6443", "kind-kind"},
+	}
+	for _, testCase := range testCases {
+		formatted := FormatContextName(testCase.contextName, testCase.server)
+		if formatted != testCase.expected {
+			t.Errorf("Did not get expected result. Got '%s', wanted '%s'", formatted, testCase.expected)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	podResource := schema.GroupResource{Resource: "pods"}
+	testCases := []struct {
+		err      error
+		expected ErrorClass
+	}{
+		{apierrors.NewUnauthorized("token expired"), ErrorAuth},
+		{apierrors.NewForbidden(podResource, "nginx", errors.New("rbac")), ErrorRBAC},
+		{apierrors.NewNotFound(podResource, "nginx"), ErrorNotFound},
+		{apierrors.NewTooManyRequests("slow down", 3), ErrorThrottled},
+		{&net.OpError{Op: "dial", Err: errors.New("i/o timeout")}, ErrorNetwork},
+		{context.DeadlineExceeded, ErrorNetwork},
+		{newAPIError("failed to get pods", apierrors.NewForbidden(podResource, "", errors.New("rbac"))), ErrorRBAC},
+		{&APIError{Class: ErrorConfig, Message: "kubeconfig error", Err: errors.New("invalid")}, ErrorConfig},
+		// names that look like errors are not errors
+		{errors.New("pod i/o timeout-7d9f Bad Request"), ErrorUnknown},
+	}
+	for _, testCase := range testCases {
+		class := ClassifyError(testCase.err)
+		if class != testCase.expected {
+			t.Errorf("Did not get expected result for '%v'. Got '%s', wanted '%s'", testCase.err, class, testCase.expected)
+		}
+	}
+
+	if delay := GetRetryDelay(apierrors.NewTooManyRequests("slow down", 3)); delay != 3*time.Second {
+		t.Errorf("Did not get expected result. Got '%v', wanted '%v'", delay, 3*time.Second)
+	}
+}
+
+func TestWatchPods(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "nginx", Namespace: "default"}})
+
+	events := make(chan PodEventType, 1)
+	watcher, err := WatchPods(context.TODO(), client, "default", func(eventType PodEventType, pod *corev1.Pod) {
+		if pod.Name == "redis" {
+			events <- eventType
+		}
+	}, nil)
+	if err != nil {
+		t.Fatalf("Did not get expected result. Got error '%v'", err)
+	}
+	defer watcher.Stop()
+
+	if podSummaries := watcher.PodSummaries(); len(podSummaries) != 1 || podSummaries[0].Name != "nginx" {
+		t.Errorf("Did not get expected result. Got '%v', wanted '%v'", podSummaries, []string{"nginx"})
+	}
+
+	_, err = client.CoreV1().Pods("default").Create(context.TODO(),
+		&corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "redis", Namespace: "default"}}, v1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case eventType := <-events:
+		if eventType != PodAdded {
+			t.Errorf("Did not get expected result. Got '%s', wanted '%s'", eventType, PodAdded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Did not get expected result. No event for the added pod")
+	}
+	if _, ok := watcher.GetPod("redis"); !ok {
+		t.Errorf("Did not get expected result. Added pod not found in cache")
+	}
+}
+
+func TestPodCache(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "nginx", Namespace: "default",
+		Labels: map[string]string{"app": "nginx"}}})
+	countGets := func() (gets int) {
+		for _, action := range client.Actions() {
+			if action.GetVerb() == "get" {
+				gets++
+			}
+		}
+		return gets
+	}
+
+	podCache := NewPodCache()
+	for i := 0; i < 3; i++ {
+		pod, err := podCache.GetPod(context.TODO(), client, "default", "nginx")
+		if err != nil {
+			t.Fatalf("Did not get expected result. Got error '%v'", err)
+		}
+		if labels := GetPodLabels(pod); labels != "app=\"nginx\"\n" {
+			t.Errorf("Did not get expected result. Got '%s', wanted '%s'", labels, "app=\"nginx\"\n")
+		}
+	}
+	if gets := countGets(); gets != 1 {
+		t.Errorf("Did not get expected result. Got '%d' gets, wanted '%d'", gets, 1)
+	}
+
+	podCache.Invalidate("default", "nginx")
+	if _, err := podCache.GetPod(context.TODO(), client, "default", "nginx"); err != nil {
+		t.Fatalf("Did not get expected result. Got error '%v'", err)
+	}
+	if gets := countGets(); gets != 2 {
+		t.Errorf("Did not get expected result. Got '%d' gets, wanted '%d'", gets, 2)
+	}
+
+	_, err := podCache.GetPod(context.TODO(), client, "default", "redis")
+	if class := ClassifyError(err); class != ErrorNotFound {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", class, ErrorNotFound)
+	}
+}
+
+func TestGetPodSummary(t *testing.T) {
+	spec := corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}, InitContainers: []corev1.Container{{Name: "init"}}}
+	initDone := []corev1.ContainerStatus{{Name: "init", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}}}
+	now := v1.Now()
+
+	tests := []struct {
+		name   string
+		pod    corev1.Pod
+		status string
+		ready  int
+		health PodHealth
+	}{
+		{"running", corev1.Pod{Spec: spec, Status: corev1.PodStatus{Phase: corev1.PodRunning, InitContainerStatuses: initDone,
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "app", Ready: true, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}}}},
+			"Running", 1, PodHealthy},
+		{"crash loop", corev1.Pod{Spec: spec, Status: corev1.PodStatus{Phase: corev1.PodRunning, InitContainerStatuses: initDone,
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "app", RestartCount: 5,
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}}}}},
+			"CrashLoopBackOff", 0, PodFailing},
+		{"oom killed", corev1.Pod{Spec: spec, Status: corev1.PodStatus{Phase: corev1.PodRunning, InitContainerStatuses: initDone,
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "app",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}}}}}},
+			"OOMKilled", 0, PodFailing},
+		{"initializing", corev1.Pod{Spec: spec, Status: corev1.PodStatus{Phase: corev1.PodPending,
+			InitContainerStatuses: []corev1.ContainerStatus{{Name: "init", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}}}},
+			"Init:0/1", 0, PodProgressing},
+		{"terminating", corev1.Pod{ObjectMeta: v1.ObjectMeta{DeletionTimestamp: &now}, Spec: spec,
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, InitContainerStatuses: initDone}},
+			"Terminating", 0, PodProgressing},
+	}
+	for _, test := range tests {
+		summary := GetPodSummary(&test.pod)
+		if summary.Status != test.status || summary.ReadyContainers != test.ready || summary.Health != test.health {
+			t.Errorf("Did not get expected result for %s. Got '%s %d %d', wanted '%s %d %d'", test.name,
+				summary.Status, summary.ReadyContainers, summary.Health, test.status, test.ready, test.health)
+		}
+	}
+}
+
+func TestGetPodContainers(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{InitContainers: []corev1.Container{{Name: "migrate"}},
+		Containers:          []corev1.Container{{Name: "app"}},
+		EphemeralContainers: []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debugger"}}}},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{{Name: "migrate",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 3}}}},
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "app",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}}}}}}
+
+	// the ephemeral container has no status yet
+	wanted := []PodContainer{
+		{Name: "migrate", Type: ContainerTypeInit, State: ContainerTerminated, Reason: "Error", ExitCode: 3},
+		{Name: "app", Type: ContainerTypeRegular, State: ContainerWaiting, Reason: "PodInitializing"},
+		{Name: "debugger", Type: ContainerTypeEphemeral, State: ContainerWaiting},
+	}
+	containers := GetPodContainers(pod)
+	if len(containers) != len(wanted) {
+		t.Fatalf("Did not get expected result. Got '%v', wanted '%v'", containers, wanted)
+	}
+	for i := range wanted {
+		if containers[i] != wanted[i] {
+			t.Errorf("Did not get expected result. Got '%v', wanted '%v'", containers[i], wanted[i])
+		}
+	}
+}
+
+func TestStreamPodLogs(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "nginx", Namespace: "default"}})
+
+	var lines []string
+	err := StreamPodLogs(context.TODO(), client, "default", "nginx", "nginx", DefaultLogOptions(), func(line string) {
+		lines = append(lines, line)
+	})
+	if err != nil {
+		t.Fatalf("Did not get expected result. Got error '%v'", err)
+	}
+	// the fake client returns a fixed log body
+	if strings.Join(lines, "\n") != "fake logs" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", strings.Join(lines, "\n"), "fake logs")
+	}
+}
+
+func TestExportPodLogs(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:   corev1.PodSpec{InitContainers: []corev1.Container{{Name: "setup"}}, Containers: []corev1.Container{{Name: "app"}}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "app", RestartCount: 2}}}}
+	client := fake.NewSimpleClientset(pod)
+
+	var bundle bytes.Buffer
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, err := ExportPodLogs(context.TODO(), client, pod, &bundle, now); err != nil {
+		t.Fatalf("Did not get expected result. Got error '%v'", err)
+	}
+	if name := GetLogBundleName("web", now); name != "web-logs-20240102-030405.zip" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", name, "web-logs-20240102-030405.zip")
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(bundle.Bytes()), int64(bundle.Len()))
+	if err != nil {
+		t.Fatalf("Did not get expected result. Got error '%v'", err)
+	}
+	var names []string
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+	// the restarted container has a previous log, the manifest comes last
+	wanted := "init-containers/setup.log containers/app.previous.log containers/app.log manifest.json"
+	if strings.Join(names, " ") != wanted {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", strings.Join(names, " "), wanted)
+	}
+
+	manifestFile, _ := archive.Open("manifest.json")
+	var manifest LogManifest
+	if err := json.NewDecoder(manifestFile).Decode(&manifest); err != nil {
+		t.Fatalf("Did not get expected result. Got error '%v'", err)
+	}
+	if len(manifest.Files) != 3 || manifest.Files[0].ContainerType != "init" || !manifest.Files[1].Previous ||
+		manifest.Files[2].Bytes != int64(len("fake logs")) {
+		t.Errorf("Did not get expected result. Got '%+v'", manifest.Files)
+	}
+}
+
+func TestLogOptions(t *testing.T) {
+	podLogOptions := DefaultLogOptions().podLogOptions("nginx", true)
+	if *podLogOptions.TailLines != 1000 || !podLogOptions.Follow || podLogOptions.SinceSeconds != nil {
+		t.Errorf("Did not get expected result. Got '%v', wanted the last 1000 lines followed", podLogOptions)
+	}
+
+	podLogOptions = LogOptions{Previous: true, Since: 90 * time.Second, Timestamps: true}.podLogOptions("nginx", false)
+	if podLogOptions.TailLines != nil || !podLogOptions.Previous || !podLogOptions.Timestamps || *podLogOptions.SinceSeconds != 90 {
+		t.Errorf("Did not get expected result. Got '%v', wanted all previous lines of the last 90s with timestamps", podLogOptions)
+	}
+}
+
+func TestStreamAggregatedLogs(t *testing.T) {
+	newPod := func(name string, app string, running bool) *corev1.Pod {
+		state := corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}}
+		if running {
+			state = corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+		}
+		return &corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": app}},
+			Spec:   corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "app", State: state}}}}
+	}
+	client := fake.NewSimpleClientset(newPod("web-1", "web", true), newPod("web-2", "web", false), newPod("db-1", "db", true))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines := make(chan string, 10)
+	done := make(chan error)
+	go func() {
+		done <- StreamAggregatedLogs(ctx, client, "default", "app=web", nil, DefaultLogOptions(), func(source LogSource, line string) {
+			lines <- source.String() + " " + line
+		}, func([]LogSource) {}, func(LogSource, error) {})
+	}()
+
+	// waiting containers and pods not matching the selector are skipped
+	if line := <-lines; line != "web-1/app fake logs" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", line, "web-1/app fake logs")
+	}
+
+	// new pods are picked up
+	if _, err := client.CoreV1().Pods("default").Create(ctx, newPod("web-3", "web", true), v1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case line := <-lines:
+		if line != "web-3/app fake logs" {
+			t.Errorf("Did not get expected result. Got '%s', wanted '%s'", line, "web-3/app fake logs")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Did not get expected result. New pod was not streamed")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Did not get expected result. Got error '%v'", err)
+	}
+	if err := StreamAggregatedLogs(context.TODO(), client, "default", "app in (", nil, DefaultLogOptions(), nil, nil, nil); err == nil {
+		t.Errorf("Did not get expected result. Got no error for an invalid selector")
+	}
+}
+
+func TestTerminalSizes(t *testing.T) {
+	sizes := NewTerminalSizes()
+	// only the latest size is sent
+	sizes.Resize(80, 24)
+	sizes.Resize(120, 40)
+	if size := sizes.Next(); size == nil || size.Width != 120 || size.Height != 40 {
+		t.Errorf("Did not get expected result. Got '%v', wanted '%v'", size, "120x40")
+	}
+	sizes.Close()
+	sizes.Resize(100, 30)
+	if size := sizes.Next(); size != nil {
+		t.Errorf("Did not get expected result. Got '%v', wanted '%v'", size, nil)
+	}
+}
+
+func TestGetExitCode(t *testing.T) {
+	err := newAPIError("command exited with code 2", utilexec.CodeExitError{Err: errors.New("exit"), Code: 2})
+	if exitCode, ok := GetExitCode(err); !ok || exitCode != 2 {
+		t.Errorf("Did not get expected result. Got '%d', wanted '%d'", exitCode, 2)
+	}
+	if _, ok := GetExitCode(newAPIError("command timed out", context.DeadlineExceeded)); ok {
+		t.Errorf("Did not get expected result. Got an exit code for a timeout")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := getExecError(ctx, nil); err != nil {
+		t.Errorf("Did not get expected result. Got '%v' for a command that succeeded as it was stopped", err)
+	}
+	if err := getExecError(ctx, errors.New("stream closed")); err == nil || err.Error() != "command stopped: context canceled" {
+		t.Errorf("Did not get expected result. Got '%v', wanted '%s'", err, "command stopped: context canceled")
+	}
+}
+
+func TestCopyTar(t *testing.T) {
+	source := t.TempDir()
+	os.MkdirAll(filepath.Join(source, "data", "sub"), 0755)
+	os.WriteFile(filepath.Join(source, "data", "a.txt"), []byte("hello"), 0644)
+	os.WriteFile(filepath.Join(source, "data", "sub", "b.txt"), []byte("world"), 0600)
+	os.Symlink("sub/b.txt", filepath.Join(source, "data", "link"))
+
+	archive := &bytes.Buffer{}
+	written, err := writeTar(archive, filepath.Join(source, "data"), 10, nil)
+	if err != nil || written.Files != 2 || written.Bytes != 10 {
+		t.Fatalf("Did not get expected result. Got '%+v' and error '%v', wanted '%d' files", written, err, 2)
+	}
+	destination := t.TempDir()
+	result, err := extractTar(archive, destination, nil)
+	if err != nil || result.Files != 2 || len(result.Skipped) != 0 {
+		t.Fatalf("Did not get expected result. Got '%+v' and error '%v', wanted '%d' files", result, err, 2)
+	}
+	if content, _ := os.ReadFile(filepath.Join(destination, "data", "link")); string(content) != "world" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", content, "world")
+	}
+
+	// entries leaving the destination are skipped
+	archive.Reset()
+	tarWriter := tar.NewWriter(archive)
+	for _, header := range []*tar.Header{
+		{Name: "../evil.txt", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "/etc/evil.txt", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "etc", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+		{Name: "up", Typeflag: tar.TypeSymlink, Linkname: ".."},
+		{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: ".."},
+		{Name: "a/b/c.txt", Typeflag: tar.TypeReg, Mode: 0644},
+	} {
+		tarWriter.WriteHeader(header)
+	}
+	tarWriter.Close()
+	destination = filepath.Join(t.TempDir(), "download")
+	result, err = extractTar(archive, destination, nil)
+	wanted := "../evil.txt,/etc/evil.txt,etc -> /etc,up -> ..,a/b/c.txt"
+	if err != nil || strings.Join(result.Skipped, ",") != wanted {
+		t.Errorf("Did not get expected result. Got '%s' and error '%v', wanted '%s'", strings.Join(result.Skipped, ","), err, wanted)
+	}
+	if _, err := os.Stat(filepath.Join(destination, "c.txt")); err == nil {
+		t.Errorf("Did not get expected result. File was written through a symlink")
+	}
+
+	// links are resolved through the links before them, l1 -> . makes l1/../x leave the destination
+	archive.Reset()
+	tarWriter = tar.NewWriter(archive)
+	for _, header := range []*tar.Header{
+		{Name: "l1", Typeflag: tar.TypeSymlink, Linkname: "."},
+		{Name: "l2", Typeflag: tar.TypeSymlink, Linkname: "l1/../x"},
+		{Name: "l3", Typeflag: tar.TypeSymlink, Linkname: "missing/../../x"},
+		{Name: "l4", Typeflag: tar.TypeSymlink, Linkname: "l1/l1/a.txt"},
+	} {
+		tarWriter.WriteHeader(header)
+	}
+	tarWriter.Close()
+	destination = filepath.Join(t.TempDir(), "download")
+	result, err = extractTar(archive, destination, nil)
+	wanted = "l2 -> l1/../x,l3 -> missing/../../x"
+	if err != nil || strings.Join(result.Skipped, ",") != wanted {
+		t.Errorf("Did not get expected result. Got '%s' and error '%v', wanted '%s'", strings.Join(result.Skipped, ","), err, wanted)
+	}
+	if _, err := os.Lstat(filepath.Join(destination, "l4")); err != nil {
+		t.Errorf("Did not get expected result. Got error '%v' for a link staying inside", err)
+	}
+
+	err = getCopyError("error downloading /data", "app", errors.New(`exec: "tar": executable file not found in $PATH`), &bytes.Buffer{})
+	if !strings.HasPrefix(err.Error(), "tar is not installed in container app") {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", err, "tar is not installed in container app")
+	}
+}
+
+func TestParseDirectoryListing(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	statOutput := "stat\n41ed|4096|1700000000|.|'.'\n41ed|4096|1700000000|..|'..'\n" +
+		"81a4|1234|1700000000|a|b.txt|'a|b.txt'\nstat: can't stat '*'\n" +
+		"a1ff|11|1700000000|current|'current' -> 'releases/v2'\n41c0|4096|1700000000|.cache|'.cache'\nlinks\ncurrent\n"
+	entries := parseDirectoryListing(statOutput, "/app", now)
+	if len(entries) != 3 {
+		t.Fatalf("Did not get expected result. Got '%d' entries, wanted '%d'", len(entries), 3)
+	}
+	if entry := entries[0]; entry.Path != "/app/.cache" || entry.Type != FileDirectory || entry.Mode != "drwx------" {
+		t.Errorf("Did not get expected result. Got '%+v'", entry)
+	}
+	if entry := entries[2]; entry.Name != "a|b.txt" || entry.Size != 1234 || entry.Mode != "-rw-r--r--" {
+		t.Errorf("Did not get expected result. Got '%+v'", entry)
+	}
+	// a link to a directory is listed and opened like one
+	if entry := entries[1]; entry.Type != FileSymlink || entry.LinkTarget != "releases/v2" || !entry.IsDirectory() {
+		t.Errorf("Did not get expected result. Got '%+v'", entry)
+	}
+
+	// ls -la of busybox and coreutils, dates without a year are in the last 6 months
+	lsOutput := "ls\ntotal 8\ndrwxr-xr-x    2 root     root          4096 Dec 24 18:30 .\n" +
+		"-rw-r--r--.   1 1000 1000 52 Dec 24 18:30 notes today.txt\n" +
+		"lrwxrwxrwx 1 root root 4 Jan  5  2021 sh -> bash\ncrw-rw-rw- 1 root root 1, 3 Mar  1 09:00 null\nlinks\n"
+	entries = parseDirectoryListing(lsOutput, "/", now)
+	if len(entries) != 3 {
+		t.Fatalf("Did not get expected result. Got '%d' entries, wanted '%d'", len(entries), 3)
+	}
+	if entry := entries[0]; entry.Name != "notes today.txt" || entry.Size != 52 || entry.ModTime.Year() != 2023 {
+		t.Errorf("Did not get expected result. Got '%+v'", entry)
+	}
+	if entry := entries[2]; entry.Name != "sh" || entry.LinkTarget != "bash" || entry.ModTime.Year() != 2021 || entry.IsDirectory() {
+		t.Errorf("Did not get expected result. Got '%+v'", entry)
+	}
+	if entry := entries[1]; entry.Type != FileOther || entry.Path != "/null" {
+		t.Errorf("Did not get expected result. Got '%+v'", entry)
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	newPod := func(name string, phase corev1.PodPhase, containers ...string) *corev1.Pod {
+		pod := &corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": "web"}},
+			Status: corev1.PodStatus{Phase: phase}}
+		for _, name := range containers {
+			pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: name})
+		}
+		return pod
+	}
+	client := fake.NewSimpleClientset(newPod("web-2", corev1.PodRunning, "app", "proxy"), newPod("web-1", corev1.PodRunning, "app"),
+		newPod("web-3", corev1.PodPending, "app"), newPod("worker-1", corev1.PodRunning, "proxy"))
+	pods, err := ListFanOutPods(context.TODO(), client, "default", "app=web", nil)
+	if err != nil || len(pods) != 3 || pods[0].Name != "web-1" {
+		t.Fatalf("Did not get expected result. Got '%d' pods and error '%v', wanted '%d' running pods", len(pods), err, 3)
+	}
+	if containers := GetFanOutContainers(pods); strings.Join(containers, ",") != "app,proxy" {
+		t.Errorf("Did not get expected result. Got '%v', wanted '%s'", containers, "app,proxy")
+	}
+	targets, missing := GetFanOutTargets(pods, "app")
+	if len(targets) != 2 || strings.Join(missing, ",") != "worker-1" {
+		t.Errorf("Did not get expected result. Got '%v' missing '%v', wanted '%d' targets", targets, missing, 2)
+	}
+
+	// nothing runs once canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := FanOutExec(ctx, client, rest.Config{}, "default", targets, "hostname", 2, 0, nil)
+	if len(results) != 2 || !errors.Is(results[1].Err, context.Canceled) || results[1].Target.Pod != "web-2" {
+		t.Errorf("Did not get expected result. Got '%+v', wanted canceled results", results)
+	}
+
+	groups := GroupFanOutResults([]FanOutResult{
+		{Target: FanOutTarget{"web-1", "app"}, Output: "v1\n"},
+		{Target: FanOutTarget{"web-2", "app"}, Output: "v2\n"},
+		{Target: FanOutTarget{"web-3", "app"}, Output: "v1\n"},
+		{Target: FanOutTarget{"web-4", "app"}, Output: "v1\n", ExitCode: 1},
+	})
+	if len(groups) != 3 || len(groups[0].Targets) != 2 || groups[0].Output != "v1\n" || groups[1].Targets[0].Pod != "web-2" {
+		t.Errorf("Did not get expected result. Got '%+v', wanted the 2 pods with v1 first", groups)
+	}
+}
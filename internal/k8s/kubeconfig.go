@@ -0,0 +1,156 @@
+package k8s
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// wait for writes to settle before reporting a kubeconfig change
+const kubeconfigDebounce = 500 * time.Millisecond
+
+// cloud provider context name patterns
+var (
+	// arn:aws:eks:us-west-2:123456789012:cluster/my-cluster
+	eksArnPattern = regexp.MustCompile(`^arn:aws[a-z-]*:eks:([a-z0-9-]+):[0-9]{12}:cluster/(.+)$`)
+	// user@my-cluster.us-west-2.eksctl.io
+	eksctlPattern = regexp.MustCompile(`^(?:[^@]+@)?([^.]+)\.([a-z0-9-]+)\.eksctl\.io$`)
+	// gke_my-project_us-central1-a_my-cluster
+	gkePattern = regexp.MustCompile(`^gke_[^_]+_([^_]+)_(.+)$`)
+	// https://my-cluster-dns-1a2b3c.hcp.eastus.azmk8s.io:443
+	aksServerPattern = regexp.MustCompile(`\.(?:hcp|privatelink)\.([a-z0-9]+)\.azmk8s\.io`)
+)
+
+// kubeconfig loading rules: --kubeconfig, otherwise the KUBECONFIG list merged in order, otherwise ~/.kube/config
+func getLoadingRules() *clientcmd.ClientConfigLoadingRules {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	return loadingRules
+}
+
+// get kubeconfig files that are merged into the client config
+func GetKubeconfigPaths() (paths []string) {
+	seen := make(map[string]bool)
+	for _, path := range getLoadingRules().GetLoadingPrecedence() {
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// get all context names from the merged kubeconfig
+func GetContexts() ([]string, error) {
+	rawConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		getLoadingRules(), &clientcmd.ConfigOverrides{}).RawConfig()
+	if err != nil {
+		return nil, &APIError{Class: ErrorConfig, Message: "failed to load kubeconfig", Err: err}
+	}
+
+	var contexts []string
+	for name := range rawConfig.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	return contexts, nil
+}
+
+// get the API server URL of the cluster referenced by each context
+func GetContextServers() (map[string]string, error) {
+	rawConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		getLoadingRules(), &clientcmd.ConfigOverrides{}).RawConfig()
+	if err != nil {
+		return nil, &APIError{Class: ErrorConfig, Message: "failed to load kubeconfig", Err: err}
+	}
+	servers := make(map[string]string)
+	for name, context := range rawConfig.Contexts {
+		if cluster, ok := rawConfig.Clusters[context.Cluster]; ok {
+			servers[name] = cluster.Server
+		}
+	}
+	return servers, nil
+}
+
+// get the namespace set for a context, "default" when none is set
+func GetContextNamespace(contextName string) (string, error) {
+	namespace, _, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		getLoadingRules(), &clientcmd.ConfigOverrides{CurrentContext: contextName}).Namespace()
+	if err != nil {
+		return "", &APIError{Class: ErrorConfig, Message: "failed to load kubeconfig", Err: err}
+	}
+	if namespace == "" {
+		return "default", nil
+	}
+	return namespace, nil
+}
+
+// shorten EKS, GKE and AKS context names to region/cluster, other names are returned as is
+func FormatContextName(contextName string, server string) string {
+	if match := eksArnPattern.FindStringSubmatch(contextName); match != nil {
+		return match[1] + "/" + match[2]
+	}
+	if match := eksctlPattern.FindStringSubmatch(contextName); match != nil {
+		return match[2] + "/" + match[1]
+	}
+	if match := gkePattern.FindStringSubmatch(contextName); match != nil {
+		return match[1] + "/" + match[2]
+	}
+	// AKS context names are the cluster name, the region is part of the server URL
+	if match := aksServerPattern.FindStringSubmatch(server); match != nil {
+		return match[1] + "/" + contextName
+	}
+	return contextName
+}
+
+// WatchKubeconfig calls onChange when one of the kubeconfig files is written, replaced or removed.
+// It blocks until ctx is done.
+func WatchKubeconfig(ctx context.Context, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// watch parent directories, kubectl replaces the file on write so a
+	// watch on the file itself is lost after the first change
+	files := make(map[string]bool)
+	for _, path := range GetKubeconfigPaths() {
+		path = filepath.Clean(path)
+		files[path] = true
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			return err
+		}
+	}
+
+	debounce := time.NewTimer(kubeconfigDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if files[filepath.Clean(event.Name)] {
+				debounce.Reset(kubeconfigDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		case <-debounce.C:
+			onChange()
+		}
+	}
+}
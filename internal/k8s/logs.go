@@ -0,0 +1,95 @@
+package k8s
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/michaeljsaenz/kview/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// lines of the log tail shown by default
+	defaultTailLines = 1000
+	// longest log line read from a stream, longer lines end the stream with an error
+	maxLogLineSize = 1024 * 1024
+)
+
+// LogOptions selects the part of a container log to show
+type LogOptions struct {
+	// logs of the previous instance of the container, e.g. before a crash
+	Previous bool
+	// only lines newer than this duration, SinceTime is used when zero
+	Since time.Duration
+	// only lines after this time, no limit when zero
+	SinceTime time.Time
+	// last lines of the log, all lines when zero
+	TailLines int64
+	// prefix every line with its RFC3339 timestamp
+	Timestamps bool
+}
+
+// DefaultLogOptions shows the last 1000 lines of the current container instance
+func DefaultLogOptions() LogOptions {
+	return LogOptions{TailLines: defaultTailLines}
+}
+
+func (o LogOptions) podLogOptions(containerName string, follow bool) *corev1.PodLogOptions {
+	podLogOptions := &corev1.PodLogOptions{Container: containerName, Follow: follow, Previous: o.Previous,
+		Timestamps: o.Timestamps}
+	if o.TailLines > 0 {
+		podLogOptions.TailLines = utils.CreateInt64(o.TailLines)
+	}
+	if o.Since > 0 {
+		// the API counts in whole seconds
+		podLogOptions.SinceSeconds = utils.CreateInt64(int64(math.Ceil(o.Since.Seconds())))
+	} else if !o.SinceTime.IsZero() {
+		sinceTime := v1.NewTime(o.SinceTime)
+		podLogOptions.SinceTime = &sinceTime
+	}
+	return podLogOptions
+}
+
+// get the status of a container, init and ephemeral containers included
+func GetContainerStatus(pod *corev1.Pod, containerName string) (corev1.ContainerStatus, bool) {
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.ContainerStatuses, pod.Status.InitContainerStatuses,
+		pod.Status.EphemeralContainerStatuses} {
+		for _, status := range statuses {
+			if status.Name == containerName {
+				return status, true
+			}
+		}
+	}
+	return corev1.ContainerStatus{}, false
+}
+
+// StreamPodLogs follows the logs of a container selected by options, calling onLine for every line.
+// It returns when ctx is canceled (nil) or the stream ends, e.g. when the container exits.
+func StreamPodLogs(ctx context.Context, c kubernetes.Interface, podNamespace string, selectedPod string, containerName string,
+	options LogOptions, onLine func(line string)) error {
+	podLogReq := c.CoreV1().Pods(podNamespace).GetLogs(selectedPod, options.podLogOptions(containerName, true))
+
+	podStream, err := podLogReq.Stream(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return newAPIError("error opening pod log stream", err)
+	}
+	defer podStream.Close()
+
+	scanner := bufio.NewScanner(podStream)
+	scanner.Buffer(make([]byte, 64*1024), maxLogLineSize)
+	for scanner.Scan() {
+		onLine(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil && !errors.Is(err, context.Canceled) {
+		return newAPIError("error reading pod log stream", err)
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PodCache keeps the pods fetched for the pod detail views so that tab switches and
+// the YAML view don't go back to the API server. Pods of a watched namespace are
+// read from the PodWatcher, other pods are fetched once and kept until invalidated.
+type PodCache struct {
+	mutex   sync.Mutex
+	watcher *PodWatcher
+	pods    map[string]*corev1.Pod
+}
+
+func NewPodCache() *PodCache {
+	return &PodCache{pods: make(map[string]*corev1.Pod)}
+}
+
+func podCacheKey(podNamespace string, podName string) string {
+	return podNamespace + "/" + podName
+}
+
+// SetWatcher reads pods of the watched namespace from watcher, nil stops using a watcher
+func (pc *PodCache) SetWatcher(watcher *PodWatcher) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.watcher = watcher
+}
+
+// GetPod returns the pod from the watcher or cache, fetching it when neither has it.
+// The returned pod is shared and must not be modified.
+func (pc *PodCache) GetPod(ctx context.Context, c kubernetes.Interface, podNamespace string, podName string) (*corev1.Pod, error) {
+	key := podCacheKey(podNamespace, podName)
+
+	pc.mutex.Lock()
+	watcher := pc.watcher
+	pod, ok := pc.pods[key]
+	pc.mutex.Unlock()
+
+	if watcher != nil && watcher.Namespace == podNamespace {
+		if pod, ok := watcher.GetPod(podName); ok {
+			return pod, nil
+		}
+	}
+	if ok {
+		return pod, nil
+	}
+
+	pod, err := GetPod(ctx, c, podNamespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	pc.mutex.Lock()
+	pc.pods[key] = pod
+	pc.mutex.Unlock()
+	return pod, nil
+}
+
+// Invalidate drops a pod so the next GetPod fetches it again
+func (pc *PodCache) Invalidate(podNamespace string, podName string) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	delete(pc.pods, podCacheKey(podNamespace, podName))
+}
+
+// Clear drops all cached pods and the watcher, used when the cluster connection changes
+func (pc *PodCache) Clear() {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.watcher = nil
+	pc.pods = make(map[string]*corev1.Pod)
+}
+
+// PodHealth groups pod statuses for coloring
+type PodHealth int
+
+const (
+	// running with all containers ready
+	PodHealthy PodHealth = iota
+	// pending, initializing, terminating or not all containers ready
+	PodProgressing
+	// crash looping, failed to pull, killed or failed
+	PodFailing
+	// all containers terminated successfully
+	PodCompleted
+)
+
+// PodSummary is a pod list row, the columns of kubectl get pods -o wide
+type PodSummary struct {
+	Name            string
+	ReadyContainers int
+	Containers      int
+	// phase or the reason the pod isn't running, e.g. CrashLoopBackOff or OOMKilled
+	Status       string
+	Restarts     int
+	CreationTime time.Time
+	IP           string
+	Node         string
+	QoS          string
+	Health       PodHealth
+}
+
+// GetPodSummary derives the pod list columns, the status follows kubectl get pods
+func GetPodSummary(pod *corev1.Pod) PodSummary {
+	restarts := 0
+	readyContainers := 0
+	reason := string(pod.Status.Phase)
+	if pod.Status.Reason != "" {
+		reason = pod.Status.Reason
+	}
+
+	initializing := false
+	for i, container := range pod.Status.InitContainerStatuses {
+		restarts += int(container.RestartCount)
+		switch {
+		case container.State.Terminated != nil && container.State.Terminated.ExitCode == 0:
+			continue
+		case container.State.Terminated != nil:
+			reason = "Init:" + getTerminatedReason(container.State.Terminated)
+		case container.State.Waiting != nil && container.State.Waiting.Reason != "" &&
+			container.State.Waiting.Reason != "PodInitializing":
+			reason = "Init:" + container.State.Waiting.Reason
+		default:
+			reason = fmt.Sprintf("Init:%d/%d", i, len(pod.Spec.InitContainers))
+		}
+		initializing = true
+		break
+	}
+
+	if !initializing {
+		restarts = 0
+		hasRunning := false
+		for i := len(pod.Status.ContainerStatuses) - 1; i >= 0; i-- {
+			container := pod.Status.ContainerStatuses[i]
+			restarts += int(container.RestartCount)
+			switch {
+			case container.State.Waiting != nil && container.State.Waiting.Reason != "":
+				reason = container.State.Waiting.Reason
+			case container.State.Terminated != nil:
+				reason = getTerminatedReason(container.State.Terminated)
+			case container.Ready && container.State.Running != nil:
+				hasRunning = true
+				readyContainers++
+			}
+		}
+		// some containers completed while others still run
+		if reason == "Completed" && hasRunning {
+			reason = "Running"
+		}
+	}
+
+	if pod.DeletionTimestamp != nil && pod.Status.Reason == "NodeLost" {
+		reason = "Unknown"
+	} else if pod.DeletionTimestamp != nil {
+		reason = "Terminating"
+	}
+
+	summary := PodSummary{
+		Name:            pod.Name,
+		ReadyContainers: readyContainers,
+		Containers:      len(pod.Spec.Containers),
+		Status:          reason,
+		Restarts:        restarts,
+		CreationTime:    pod.CreationTimestamp.Time,
+		IP:              pod.Status.PodIP,
+		Node:            pod.Spec.NodeName,
+		QoS:             string(pod.Status.QOSClass),
+	}
+	summary.Health = getPodHealth(reason, readyContainers, len(pod.Spec.Containers), initializing)
+	return summary
+}
+
+func getTerminatedReason(state *corev1.ContainerStateTerminated) string {
+	switch {
+	case state.Reason != "":
+		return state.Reason
+	case state.Signal != 0:
+		return fmt.Sprintf("Signal:%d", state.Signal)
+	}
+	return fmt.Sprintf("ExitCode:%d", state.ExitCode)
+}
+
+func getPodHealth(reason string, readyContainers int, containers int, initializing bool) PodHealth {
+	switch {
+	case reason == string(corev1.PodRunning) && readyContainers == containers:
+		return PodHealthy
+	case reason == "Completed" || reason == string(corev1.PodSucceeded):
+		return PodCompleted
+	case reason == string(corev1.PodRunning), reason == string(corev1.PodPending), reason == "ContainerCreating",
+		reason == "PodInitializing", reason == "Terminating":
+		return PodProgressing
+	case initializing && strings.HasPrefix(reason, "Init:") && strings.Contains(reason, "/"):
+		return PodProgressing
+	}
+	return PodFailing
+}
+
+// ContainerType tells regular, init and ephemeral containers apart
+type ContainerType string
+
+const (
+	ContainerTypeInit      ContainerType = "init"
+	ContainerTypeRegular   ContainerType = "container"
+	ContainerTypeEphemeral ContainerType = "ephemeral"
+)
+
+// ContainerState is the state of a container in its status
+type ContainerState string
+
+const (
+	ContainerWaiting    ContainerState = "waiting"
+	ContainerRunning    ContainerState = "running"
+	ContainerTerminated ContainerState = "terminated"
+)
+
+// PodContainer is a container of a pod with its type and current state
+type PodContainer struct {
+	Name  string
+	Image string
+	Type  ContainerType
+	State ContainerState
+	// waiting or terminated reason, e.g. CrashLoopBackOff or Completed
+	Reason string
+	// exit code of a terminated container
+	ExitCode     int32
+	RestartCount int32
+}
+
+// GetPodContainers returns the init, regular and ephemeral containers of a pod in that order,
+// containers without a status yet are waiting
+func GetPodContainers(pod *corev1.Pod) []PodContainer {
+	var containers []PodContainer
+	addContainer := func(name string, image string, containerType ContainerType) {
+		container := PodContainer{Name: name, Image: image, Type: containerType, State: ContainerWaiting}
+		status, ok := GetContainerStatus(pod, name)
+		switch {
+		case !ok:
+		case status.State.Running != nil:
+			container.State = ContainerRunning
+		case status.State.Terminated != nil:
+			container.State = ContainerTerminated
+			container.Reason = getTerminatedReason(status.State.Terminated)
+			container.ExitCode = status.State.Terminated.ExitCode
+		case status.State.Waiting != nil:
+			container.Reason = status.State.Waiting.Reason
+		}
+		container.RestartCount = status.RestartCount
+		containers = append(containers, container)
+	}
+
+	for _, container := range pod.Spec.InitContainers {
+		addContainer(container.Name, container.Image, ContainerTypeInit)
+	}
+	for _, container := range pod.Spec.Containers {
+		addContainer(container.Name, container.Image, ContainerTypeRegular)
+	}
+	for _, container := range pod.Spec.EphemeralContainers {
+		addContainer(container.Name, container.Image, ContainerTypeEphemeral)
+	}
+	return containers
+}
+
+// GetAge formats the time since t, days once older than a day
+func GetAge(t time.Time) string {
+	age := time.Since(t).Round(time.Second)
+	if int(math.Trunc(age.Hours())) >= 24 {
+		ageInDays := int(math.Trunc(age.Hours())) / 24
+		return strconv.Itoa(ageInDays) + "d"
+	}
+	return age.String()
+}
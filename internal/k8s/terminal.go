@@ -0,0 +1,150 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// shells tried by DetectShell, in order of preference
+var terminalShells = []string{"bash", "ash", "sh"}
+
+// GetTerminalShells returns the shells a terminal can start, in order of preference
+func GetTerminalShells() []string {
+	return append([]string(nil), terminalShells...)
+}
+
+// TerminalSizes passes the size of a terminal to its TTY session, only the latest size is kept
+type TerminalSizes struct {
+	sizes chan remotecommand.TerminalSize
+	done  chan struct{}
+	once  sync.Once
+}
+
+func NewTerminalSizes() *TerminalSizes {
+	return &TerminalSizes{sizes: make(chan remotecommand.TerminalSize, 1), done: make(chan struct{})}
+}
+
+// Resize sets the size of the terminal in characters, it doesn't block
+func (s *TerminalSizes) Resize(columns uint16, rows uint16) {
+	size := remotecommand.TerminalSize{Width: columns, Height: rows}
+	for {
+		if s.closed() {
+			return
+		}
+		select {
+		case <-s.done:
+			return
+		case s.sizes <- size:
+			return
+		default:
+			// replace the size not sent yet
+			select {
+			case <-s.sizes:
+			default:
+			}
+		}
+	}
+}
+
+// Next returns the next size, nil once closed. It implements remotecommand.TerminalSizeQueue.
+func (s *TerminalSizes) Next() *remotecommand.TerminalSize {
+	if s.closed() {
+		return nil
+	}
+	select {
+	case <-s.done:
+		return nil
+	case size := <-s.sizes:
+		return &size
+	}
+}
+
+func (s *TerminalSizes) closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close ends the sizes of a finished session
+func (s *TerminalSizes) Close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// create an executor of a command in a container
+func newExecutor(client kubernetes.Interface, config rest.Config, podName string, containerName string, podNamespace string,
+	option *corev1.PodExecOptions) (remotecommand.Executor, error) {
+	option.Container = containerName
+	req := client.CoreV1().RESTClient().Post().Resource("pods").Name(podName).
+		Namespace(podNamespace).SubResource("exec")
+	req.VersionedParams(option, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(&config, "POST", req.URL())
+	if err != nil {
+		return nil, newAPIError("error creating exec session", err)
+	}
+	return exec, nil
+}
+
+// DetectShell returns the first of bash, ash and sh that runs in a container
+func DetectShell(ctx context.Context, client kubernetes.Interface, config rest.Config, podName string, containerName string,
+	podNamespace string) (string, error) {
+	var lastErr error
+	for _, shell := range terminalShells {
+		exec, err := newExecutor(client, config, podName, containerName, podNamespace,
+			&corev1.PodExecOptions{Command: []string{shell, "-c", "exit 0"}, Stdout: true, Stderr: true})
+		if err != nil {
+			return "", err
+		}
+		err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: io.Discard, Stderr: io.Discard})
+		if err == nil {
+			return shell, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		lastErr = err
+	}
+	return "", newAPIError(fmt.Sprintf("no shell (%v) found in container %s", terminalShells, containerName), lastErr)
+}
+
+// GetExitCode returns the exit code of a command that exited with an error, false for other errors
+func GetExitCode(err error) (int, bool) {
+	var exitError utilexec.ExitError
+	if errors.As(err, &exitError) && exitError.Exited() {
+		return exitError.ExitStatus(), true
+	}
+	return 0, false
+}
+
+// StartTerminal runs command in a container with a TTY, like kubectl exec -it. Input is read from
+// stdin, the output of the terminal is written to stdout and sizes resizes it. It returns when the
+// command exits or ctx is canceled, a non-zero exit code is returned as an error (see GetExitCode).
+func StartTerminal(ctx context.Context, client kubernetes.Interface, config rest.Config, podName string, containerName string,
+	podNamespace string, command []string, stdin io.Reader, stdout io.Writer, sizes *TerminalSizes) error {
+	defer sizes.Close()
+	// a TTY merges stderr into stdout
+	exec, err := newExecutor(client, config, podName, containerName, podNamespace,
+		&corev1.PodExecOptions{Command: command, Stdin: true, Stdout: true, TTY: true})
+	if err != nil {
+		return err
+	}
+	err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{Stdin: stdin, Stdout: stdout, Tty: true,
+		TerminalSizeQueue: sizes})
+	if err != nil && ctx.Err() == nil {
+		return newAPIError("terminal session failed", err)
+	}
+	return nil
+}
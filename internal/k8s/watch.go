@@ -0,0 +1,157 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// full relist interval of the pod informer, catches missed watch events
+	podResyncPeriod = 5 * time.Minute
+	// time allowed for the initial pod list before giving up on the watch
+	podSyncTimeout = 15 * time.Second
+)
+
+// PodEventType is the kind of change reported by a PodWatcher
+type PodEventType string
+
+const (
+	PodAdded   PodEventType = "Added"
+	PodUpdated PodEventType = "Updated"
+	PodDeleted PodEventType = "Deleted"
+)
+
+// PodWatcher keeps the pods of one namespace in sync through a shared informer,
+// call Stop when the namespace is no longer shown
+type PodWatcher struct {
+	Namespace string
+
+	lister   corelisters.PodLister
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// WatchPods starts an informer for namespace and waits for the initial list, canceling ctx
+// aborts the wait. onChange is called for every pod change after the initial list, onError when the watch fails.
+func WatchPods(ctx context.Context, c kubernetes.Interface, namespace string, onChange func(eventType PodEventType, pod *corev1.Pod),
+	onError func(err error)) (*PodWatcher, error) {
+	factory := informers.NewSharedInformerFactoryWithOptions(c, podResyncPeriod, informers.WithNamespace(namespace))
+	podInformer := factory.Core().V1().Pods()
+	informer := podInformer.Informer()
+
+	// drop managed fields to keep the cache small
+	err := informer.SetTransform(func(obj interface{}) (interface{}, error) {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			pod.ManagedFields = nil
+		}
+		return obj, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// report each distinct watch error once, the reflector keeps retrying with backoff
+	var lastError error
+	var errorMutex sync.Mutex
+	err = informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+		errorMutex.Lock()
+		defer errorMutex.Unlock()
+		if lastError != nil && err.Error() == lastError.Error() {
+			return
+		}
+		lastError = err
+		if onError != nil {
+			onError(newAPIError("failed to watch pods", err))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	w := &PodWatcher{Namespace: namespace, lister: podInformer.Lister(), stopCh: make(chan struct{})}
+
+	var synced atomic.Bool
+	notify := func(eventType PodEventType, obj interface{}) {
+		if !synced.Load() {
+			return
+		}
+		// deleted objects can arrive as tombstones
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if pod, ok := obj.(*corev1.Pod); ok && onChange != nil {
+			onChange(eventType, pod)
+		}
+	}
+	_, err = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { notify(PodAdded, obj) },
+		UpdateFunc: func(_, obj interface{}) { notify(PodUpdated, obj) },
+		DeleteFunc: func(obj interface{}) { notify(PodDeleted, obj) },
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	factory.Start(w.stopCh)
+
+	// wait for the initial list, give up when it doesn't arrive in time
+	syncCtx, cancel := context.WithTimeout(ctx, podSyncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), informer.HasSynced) {
+		w.Stop()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		errorMutex.Lock()
+		defer errorMutex.Unlock()
+		message := fmt.Sprintf("failed to sync pods in namespace %s", namespace)
+		if lastError != nil {
+			return nil, newAPIError(message, lastError)
+		}
+		return nil, newAPIError(message, context.DeadlineExceeded)
+	}
+	// events of the initial list are skipped, callers read it with PodSummaries
+	synced.Store(true)
+
+	return w, nil
+}
+
+// Stop ends the watch and releases the pod cache
+func (w *PodWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopCh)
+	})
+}
+
+// get the pod list rows from the cache, sorted by name
+func (w *PodWatcher) PodSummaries() (podSummaries []PodSummary) {
+	pods, err := w.lister.Pods(w.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil
+	}
+	for _, pod := range pods {
+		podSummaries = append(podSummaries, GetPodSummary(pod))
+	}
+	sort.Slice(podSummaries, func(i, j int) bool { return podSummaries[i].Name < podSummaries[j].Name })
+	return podSummaries
+}
+
+// get a pod from the cache
+func (w *PodWatcher) GetPod(podName string) (*corev1.Pod, bool) {
+	pod, err := w.lister.Pods(w.Namespace).Get(podName)
+	if err != nil {
+		return nil, false
+	}
+	return pod, true
+}
@@ -0,0 +1,235 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/michaeljsaenz/kview/internal/k8s"
+	"k8s.io/client-go/kubernetes"
+)
+
+// colors of the pod/container prefixes of aggregated log lines, indexes of the 256 color palette
+var logSourceColors = []int{33, 42, 214, 170, 39, 148, 208, 141, 44, 203, 118, 178}
+
+// lines of every container shown when aggregated logs start
+const aggregatedTailLines = "100"
+
+// AggregatedLogs streams the logs of all pods matching a label selector and a pod name pattern into
+// one log view, like stern. Every line is prefixed with its pod and container in a color of its own.
+type AggregatedLogs struct {
+	Content fyne.CanvasObject
+
+	clientset kubernetes.Clientset
+	namespace string
+	showError func(err error)
+
+	view            *LogView
+	search          *LogSearch
+	selectorEntry   *widget.Entry
+	podEntry        *widget.Entry
+	tailSelect      *widget.Select
+	timestampsCheck *widget.Check
+	startButton     *widget.Button
+	sourcesLabel    *widget.Label
+
+	mutex     sync.Mutex
+	pending   []string
+	streaming bool
+	cancel    context.CancelFunc
+}
+
+// ShowAggregatedLogs opens a window streaming the logs of the pods in namespace,
+// podPattern preselects the pods by name
+func ShowAggregatedLogs(app fyne.App, clientset kubernetes.Clientset, namespace string, podPattern string) {
+	win := app.NewWindow("Aggregated Logs: " + namespace)
+	a := NewAggregatedLogs(app.Preferences(), clientset, namespace, podPattern, func(err error) {
+		dialog.ShowError(err, win)
+	})
+	win.SetOnClosed(a.Stop)
+	win.SetContent(a.Content)
+	win.Resize(fyne.NewSize(1200, 700))
+	win.Show()
+}
+
+func NewAggregatedLogs(prefs fyne.Preferences, clientset kubernetes.Clientset, namespace string, podPattern string,
+	showError func(err error)) *AggregatedLogs {
+	a := &AggregatedLogs{clientset: clientset, namespace: namespace, showError: showError, cancel: func() {}}
+
+	a.view = NewLogView()
+	a.search = NewLogSearch(a.view, prefs)
+
+	a.selectorEntry = widget.NewEntry()
+	a.selectorEntry.SetPlaceHolder("Label selector: app=web,tier!=cache")
+	a.selectorEntry.OnSubmitted = func(string) { a.Start() }
+	a.podEntry = widget.NewEntry()
+	a.podEntry.SetPlaceHolder("Pod name regex: ^web-")
+	a.podEntry.SetText(podPattern)
+	a.podEntry.OnSubmitted = func(string) { a.Start() }
+	a.tailSelect = widget.NewSelect(logTailOptions[:len(logTailOptions)-1], nil)
+	a.tailSelect.Selected = aggregatedTailLines
+	a.timestampsCheck = widget.NewCheck("Timestamps", nil)
+	a.startButton = widget.NewButtonWithIcon("Start", theme.MediaPlayIcon(), func() {
+		if a.isStreaming() {
+			a.Stop()
+		} else {
+			a.Start()
+		}
+	})
+	colorsCheck := widget.NewCheck("Colors", a.view.SetColors)
+	colorsCheck.Checked = true
+	a.sourcesLabel = widget.NewLabel("Streams every container of the matching pods, new pods are added while streaming")
+	a.sourcesLabel.Wrapping = fyne.TextTruncate
+
+	query := container.NewBorder(nil, nil, nil,
+		container.NewHBox(widget.NewLabel("Tail"), a.tailSelect, a.timestampsCheck, colorsCheck, a.startButton),
+		container.NewGridWithColumns(2, a.selectorEntry, a.podEntry))
+	toolbar := container.NewVBox(query, a.search.Content, a.sourcesLabel)
+	a.Content = container.NewBorder(toolbar, nil, nil, nil, a.view)
+	return a
+}
+
+func (a *AggregatedLogs) isStreaming() bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.streaming
+}
+
+func (a *AggregatedLogs) setStreaming(streaming bool) {
+	a.mutex.Lock()
+	a.streaming = streaming
+	a.mutex.Unlock()
+	if streaming {
+		a.startButton.SetText("Stop")
+		a.startButton.SetIcon(theme.MediaStopIcon())
+	} else {
+		a.startButton.SetText("Start")
+		a.startButton.SetIcon(theme.MediaPlayIcon())
+	}
+}
+
+// Start streams the logs of the pods matching the selector and pod name pattern, replacing the shown lines
+func (a *AggregatedLogs) Start() {
+	var podPattern *regexp.Regexp
+	if pattern := strings.TrimSpace(a.podEntry.Text); pattern != "" {
+		var err error
+		if podPattern, err = regexp.Compile(pattern); err != nil {
+			a.showError(fmt.Errorf("invalid pod name regex %q: %w", pattern, err))
+			return
+		}
+	}
+	tailLines, _ := strconv.ParseInt(a.tailSelect.Selected, 10, 64)
+	options := k8s.LogOptions{TailLines: tailLines, Timestamps: a.timestampsCheck.Checked}
+	selector := strings.TrimSpace(a.selectorEntry.Text)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.mutex.Lock()
+	a.cancel()
+	a.cancel = cancel
+	a.pending = nil
+	a.mutex.Unlock()
+
+	a.view.SetText("")
+	a.search.UpdateCount()
+	a.sourcesLabel.SetText("Waiting for pods...")
+	a.setStreaming(true)
+
+	// lines are collected by the streams and shown in batches
+	go func() {
+		ticker := time.NewTicker(logFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.flush()
+			}
+		}
+	}()
+
+	go func() {
+		err := k8s.StreamAggregatedLogs(ctx, &a.clientset, a.namespace, selector, podPattern, options,
+			func(source k8s.LogSource, line string) {
+				a.mutex.Lock()
+				a.pending = append(a.pending, formatLogSourceLine(source, line))
+				a.mutex.Unlock()
+			},
+			a.showSources,
+			func(source k8s.LogSource, err error) {
+				a.mutex.Lock()
+				a.pending = append(a.pending, formatLogSourceLine(source, "log stream failed: "+err.Error()))
+				a.mutex.Unlock()
+			})
+		if ctx.Err() != nil {
+			return
+		}
+		cancel()
+		a.flush()
+		a.setStreaming(false)
+		if err != nil {
+			a.sourcesLabel.SetText("Stream failed")
+			a.showError(err)
+		}
+	}()
+}
+
+// Stop ends all log streams
+func (a *AggregatedLogs) Stop() {
+	a.mutex.Lock()
+	a.cancel()
+	a.mutex.Unlock()
+	a.flush()
+	a.setStreaming(false)
+}
+
+func (a *AggregatedLogs) showSources(sources []k8s.LogSource) {
+	pods := make(map[string]bool)
+	names := make([]string, len(sources))
+	for i, source := range sources {
+		pods[source.Pod] = true
+		names[i] = source.String()
+	}
+	a.sourcesLabel.SetText(fmt.Sprintf("Streaming %d containers in %d pods: %s", len(sources), len(pods),
+		strings.Join(names, ", ")))
+}
+
+// add the collected lines to the view, keeping it scrolled to the bottom unless scrolled up
+func (a *AggregatedLogs) flush() {
+	a.mutex.Lock()
+	pending := a.pending
+	a.pending = nil
+	a.mutex.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	atBottom := a.view.AtBottom()
+	a.view.AppendLines(pending)
+	a.search.UpdateCount()
+	if atBottom {
+		a.view.ScrollToBottom()
+	}
+}
+
+// prefix a log line with its pod and container, colored by an ANSI escape sequence
+func formatLogSourceLine(source k8s.LogSource, line string) string {
+	return "\x1b[38;5;" + strconv.Itoa(getLogSourceColor(source)) + "m" + source.String() + "\x1b[0m " + line
+}
+
+// the same container always gets the same color
+func getLogSourceColor(source k8s.LogSource) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(source.String()))
+	return logSourceColors[hash.Sum32()%uint32(len(logSourceColors))]
+}
@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"strconv"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/michaeljsaenz/kview/internal/k8s"
+)
+
+// ContainerControls shows the containers of the selected pod as log tabs and exec buttons, labeled
+// with their type and state. The first log tab is the pod log tab, container tabs follow in the order
+// of the containers.
+type ContainerControls struct {
+	logTabs     *container.AppTabs
+	execButtons []*widget.Button
+
+	mutex      sync.Mutex
+	containers []k8s.PodContainer
+}
+
+func NewContainerControls(logTabs *container.AppTabs, execButtons []*widget.Button) *ContainerControls {
+	return &ContainerControls{logTabs: logTabs, execButtons: execButtons}
+}
+
+// Show labels the log tabs and exec buttons with the containers, tabs of new containers (e.g. ephemeral
+// debug containers) are added. Exec is only offered for running containers.
+func (c *ContainerControls) Show(containers []k8s.PodContainer) {
+	c.mutex.Lock()
+	c.containers = containers
+	c.mutex.Unlock()
+
+	for i, podContainer := range containers {
+		label := getContainerLabel(podContainer)
+		icon := getContainerStateIcon(podContainer)
+		if i+1 < len(c.logTabs.Items) {
+			item := c.logTabs.Items[i+1]
+			item.Text, item.Icon = label, icon
+		} else {
+			c.logTabs.Append(container.NewTabItemWithIcon(label, icon, widget.NewLabel("")))
+		}
+	}
+	c.logTabs.Refresh()
+
+	for i, button := range c.execButtons {
+		if i >= len(containers) {
+			button.Hide()
+			continue
+		}
+		button.SetText(getContainerLabel(containers[i]))
+		if containers[i].State == k8s.ContainerRunning {
+			button.Enable()
+		} else {
+			button.Disable()
+		}
+		button.Show()
+	}
+}
+
+// Clear forgets the containers and hides the exec buttons, the log tabs are removed by the caller
+func (c *ContainerControls) Clear() {
+	c.mutex.Lock()
+	c.containers = nil
+	c.mutex.Unlock()
+	for _, button := range c.execButtons {
+		button.Hide()
+	}
+}
+
+// LogTabContainer returns the container of a log tab, false for the pod log tab
+func (c *ContainerControls) LogTabContainer(item *container.TabItem) (k8s.PodContainer, bool) {
+	for i, tabItem := range c.logTabs.Items {
+		if tabItem == item {
+			return c.getContainer(i - 1)
+		}
+	}
+	return k8s.PodContainer{}, false
+}
+
+// ExecContainer returns the container of the exec button at index
+func (c *ContainerControls) ExecContainer(index int) (k8s.PodContainer, bool) {
+	return c.getContainer(index)
+}
+
+func (c *ContainerControls) getContainer(index int) (k8s.PodContainer, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if index < 0 || index >= len(c.containers) {
+		return k8s.PodContainer{}, false
+	}
+	return c.containers[index], true
+}
+
+// label of a container with its type badge and state, e.g. "migrate [init] · terminated: Error (exit 1)"
+func getContainerLabel(podContainer k8s.PodContainer) string {
+	label := podContainer.Name
+	if podContainer.Type != k8s.ContainerTypeRegular {
+		label += " [" + string(podContainer.Type) + "]"
+	}
+	label += " · " + string(podContainer.State)
+	if podContainer.Reason != "" {
+		label += ": " + podContainer.Reason
+	}
+	if podContainer.State == k8s.ContainerTerminated {
+		label += " (exit " + strconv.Itoa(int(podContainer.ExitCode)) + ")"
+	}
+	return label
+}
+
+func getContainerStateIcon(podContainer k8s.PodContainer) fyne.Resource {
+	switch {
+	case podContainer.State == k8s.ContainerRunning:
+		return theme.MediaPlayIcon()
+	case podContainer.State == k8s.ContainerTerminated && podContainer.ExitCode == 0:
+		return theme.ConfirmIcon()
+	case podContainer.State == k8s.ContainerTerminated:
+		return theme.ErrorIcon()
+	}
+	return theme.HistoryIcon()
+}
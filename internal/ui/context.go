@@ -0,0 +1,216 @@
+package ui
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image/color"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/michaeljsaenz/kview/internal/k8s"
+)
+
+// preference key prefixes for per-context display settings
+const (
+	contextAliasKey = "context.alias."
+	contextColorKey = "context.color."
+)
+
+// colors used to tell cluster sessions apart
+var contextPalette = []color.NRGBA{
+	{R: 57, G: 112, B: 228, A: 255},
+	{R: 46, G: 160, B: 67, A: 255},
+	{R: 219, G: 109, B: 40, A: 255},
+	{R: 163, G: 73, B: 164, A: 255},
+	{R: 0, G: 150, B: 160, A: 255},
+	{R: 200, G: 60, B: 90, A: 255},
+}
+
+// API server of every context, read from the kubeconfig once and dropped by resetContextServers
+var (
+	contextServersMutex sync.Mutex
+	contextServers      map[string]string
+)
+
+// names are shown without the server when the kubeconfig doesn't load, the workspace
+// reports that error when loading the contexts
+func getContextServers() map[string]string {
+	contextServersMutex.Lock()
+	defer contextServersMutex.Unlock()
+	if contextServers == nil {
+		servers, err := k8s.GetContextServers()
+		if err != nil {
+			return nil
+		}
+		contextServers = servers
+	}
+	return contextServers
+}
+
+// reload the context servers on the next display name, after the kubeconfig changed
+func resetContextServers() {
+	contextServersMutex.Lock()
+	contextServers = nil
+	contextServersMutex.Unlock()
+}
+
+// ContextDropdown lists contexts by display name, selecting one reports the context name
+type ContextDropdown struct {
+	*widget.Select
+
+	prefs        fyne.Preferences
+	contextMutex sync.RWMutex
+	contexts     map[string]string
+}
+
+func CreateContextDropdown(prefs fyne.Preferences, contexts []string, onSelected func(string)) *ContextDropdown {
+	// context dropdown works as a switcher, selection is cleared once handled
+	d := &ContextDropdown{prefs: prefs}
+	d.Select = widget.NewSelect(nil, nil)
+	d.PlaceHolder = "Switch context..."
+	d.OnChanged = func(selectedOption string) {
+		if selectedOption == "" {
+			return
+		}
+		d.contextMutex.RLock()
+		selectedContext := d.contexts[selectedOption]
+		d.contextMutex.RUnlock()
+		onSelected(selectedContext)
+		d.ClearSelected()
+	}
+	d.SetContexts(contexts)
+	return d
+}
+
+// SetContexts replaces the listed contexts, names shared by several contexts fall back to the full name
+func (d *ContextDropdown) SetContexts(contexts []string) {
+	servers := getContextServers()
+	displayNames := make(map[string]string)
+	displayCount := make(map[string]int)
+	for _, contextName := range contexts {
+		displayName := getContextDisplayName(d.prefs, contextName, servers[contextName])
+		displayNames[contextName] = displayName
+		displayCount[displayName]++
+	}
+
+	options := make([]string, 0, len(contexts))
+	lookup := make(map[string]string)
+	for _, contextName := range contexts {
+		option := displayNames[contextName]
+		if displayCount[option] > 1 {
+			option = contextName
+		}
+		options = append(options, option)
+		lookup[option] = contextName
+	}
+
+	d.contextMutex.Lock()
+	d.contexts = lookup
+	d.contextMutex.Unlock()
+	d.Options = options
+	d.Refresh()
+}
+
+// get the alias for a context, otherwise the short cloud provider name
+func GetContextDisplayName(prefs fyne.Preferences, contextName string) string {
+	return getContextDisplayName(prefs, contextName, getContextServers()[contextName])
+}
+
+func getContextDisplayName(prefs fyne.Preferences, contextName string, server string) string {
+	if contextName == "" {
+		return "(none)"
+	}
+	if alias := prefs.String(contextAliasKey + contextName); alias != "" {
+		return alias
+	}
+	return k8s.FormatContextName(contextName, server)
+}
+
+// get the user color for a context, otherwise its palette color
+func GetContextColor(prefs fyne.Preferences, contextName string) color.Color {
+	if c, err := hexToColor(prefs.String(contextColorKey + contextName)); err == nil {
+		return c
+	}
+	return ContextColor(contextName)
+}
+
+// get the palette color for a context, the same context always gets the same color
+func ContextColor(contextName string) color.Color {
+	h := fnv.New32a()
+	h.Write([]byte(contextName))
+	return contextPalette[h.Sum32()%uint32(len(contextPalette))]
+}
+
+// save alias and color for a context, empty alias or nil color restore the defaults
+func SetContextDisplay(prefs fyne.Preferences, contextName string, alias string, c color.Color) {
+	if alias == "" {
+		prefs.RemoveValue(contextAliasKey + contextName)
+	} else {
+		prefs.SetString(contextAliasKey+contextName, alias)
+	}
+	if c == nil {
+		prefs.RemoveValue(contextColorKey + contextName)
+	} else {
+		prefs.SetString(contextColorKey+contextName, colorToHex(c))
+	}
+}
+
+func ShowContextDisplayDialog(prefs fyne.Preferences, contextName string, win fyne.Window, onSaved func()) {
+	aliasEntry := widget.NewEntry()
+	aliasEntry.SetText(prefs.String(contextAliasKey + contextName))
+	aliasEntry.SetPlaceHolder(GetContextDisplayName(prefs, contextName))
+
+	// nil color keeps the palette color
+	var selectedColor color.Color
+	if c, err := hexToColor(prefs.String(contextColorKey + contextName)); err == nil {
+		selectedColor = c
+	}
+	colorPreview := canvas.NewRectangle(GetContextColor(prefs, contextName))
+	colorPreview.SetMinSize(fyne.NewSize(60, 20))
+
+	chooseColor := widget.NewButton("Choose...", func() {
+		picker := dialog.NewColorPicker("Context Color", contextName, func(c color.Color) {
+			selectedColor = c
+			colorPreview.FillColor = c
+			colorPreview.Refresh()
+		}, win)
+		picker.Advanced = true
+		picker.Show()
+	})
+	resetColor := widget.NewButton("Default", func() {
+		selectedColor = nil
+		colorPreview.FillColor = ContextColor(contextName)
+		colorPreview.Refresh()
+	})
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Context", widget.NewLabel(contextName)),
+		widget.NewFormItem("Alias", aliasEntry),
+		widget.NewFormItem("Color", container.NewHBox(colorPreview, chooseColor, resetColor)),
+	}
+	dialog.ShowForm("Context Display", "Save", "Cancel", items, func(save bool) {
+		if !save {
+			return
+		}
+		SetContextDisplay(prefs, contextName, aliasEntry.Text, selectedColor)
+		onSaved()
+	}, win)
+}
+
+func colorToHex(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+}
+
+func hexToColor(hex string) (color.Color, error) {
+	var n color.NRGBA
+	if _, err := fmt.Sscanf(hex, "#%02x%02x%02x", &n.R, &n.G, &n.B); err != nil {
+		return nil, fmt.Errorf("invalid color %q: %v", hex, err)
+	}
+	n.A = 255
+	return n, nil
+}
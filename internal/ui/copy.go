@@ -0,0 +1,249 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/michaeljsaenz/kview/internal/k8s"
+	"github.com/michaeljsaenz/kview/internal/utils"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// FileCopy copies files and directories between a container and local paths chosen with a file dialog,
+// like kubectl cp. The progress is shown while copying and a copy can be canceled.
+type FileCopy struct {
+	Content fyne.CanvasObject
+
+	client        kubernetes.Interface
+	config        rest.Config
+	podNamespace  string
+	podName       string
+	containerName string
+	showError     func(err error)
+
+	pathEntry     *widget.Entry
+	buttons       []*widget.Button
+	cancelButton  *widget.Button
+	progressBar   *widget.ProgressBar
+	activityBar   *widget.ProgressBarInfinite
+	progressLabel *widget.Label
+
+	mutex    sync.Mutex
+	progress k8s.CopyProgress
+	cancel   context.CancelFunc
+}
+
+func NewFileCopy(client kubernetes.Interface, config rest.Config, podNamespace string, podName string,
+	containerName string, showError func(err error)) *FileCopy {
+	c := &FileCopy{client: client, config: config, podNamespace: podNamespace, podName: podName,
+		containerName: containerName, showError: showError, cancel: func() {}}
+
+	c.pathEntry = widget.NewEntry()
+	c.pathEntry.SetPlaceHolder("Container path: /var/log/app")
+	c.buttons = []*widget.Button{
+		widget.NewButtonWithIcon("Download", theme.DownloadIcon(), c.download),
+		widget.NewButtonWithIcon("Upload File", theme.UploadIcon(), func() { c.upload(false) }),
+		widget.NewButtonWithIcon("Upload Folder", theme.FolderOpenIcon(), func() { c.upload(true) }),
+	}
+	c.cancelButton = widget.NewButtonWithIcon("Cancel", theme.CancelIcon(), c.Cancel)
+	c.cancelButton.Disable()
+	c.progressBar = widget.NewProgressBar()
+	c.progressBar.Hide()
+	// downloads have no known size
+	c.activityBar = widget.NewProgressBarInfinite()
+	c.activityBar.Stop()
+	c.activityBar.Hide()
+	c.progressLabel = widget.NewLabel("Download copies the file or directory at the path to a local folder, " +
+		"uploads go into the directory at the path. tar is needed in the container.")
+	c.progressLabel.Wrapping = fyne.TextWrapWord
+
+	pathBar := container.NewBorder(nil, nil, widget.NewLabel("Path"),
+		container.NewHBox(c.buttons[0], c.buttons[1], c.buttons[2], c.cancelButton), c.pathEntry)
+	c.Content = container.NewVBox(pathBar, c.progressBar, c.activityBar, c.progressLabel)
+	return c
+}
+
+// SetPath sets the container path to copy from or to
+func (c *FileCopy) SetPath(remotePath string) {
+	c.pathEntry.SetText(remotePath)
+}
+
+// Cancel stops the running copy
+func (c *FileCopy) Cancel() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cancel()
+}
+
+func (c *FileCopy) getWindow() fyne.Window {
+	return getWindowForObject(c.pathEntry)
+}
+
+// the container path of the entry, it must be absolute
+func (c *FileCopy) getRemotePath() (string, bool) {
+	remotePath := strings.TrimSpace(c.pathEntry.Text)
+	if !strings.HasPrefix(remotePath, "/") {
+		c.showError(fmt.Errorf("enter an absolute container path, e.g. /tmp"))
+		return "", false
+	}
+	return remotePath, true
+}
+
+// download the container path into a local folder
+func (c *FileCopy) download() {
+	remotePath, ok := c.getRemotePath()
+	window := c.getWindow()
+	if !ok || window == nil {
+		return
+	}
+	dialog.ShowFolderOpen(func(folder fyne.ListableURI, err error) {
+		if err != nil {
+			c.showError(err)
+			return
+		}
+		if folder == nil {
+			return
+		}
+		c.start(true, func(ctx context.Context, progress func(k8s.CopyProgress)) (string, error) {
+			result, err := k8s.CopyFromContainer(ctx, c.client, c.config, c.podName, c.containerName, c.podNamespace,
+				remotePath, folder.Path(), progress)
+			return getCopySummary("Downloaded", result.CopyProgress, remotePath, folder.Path(), result.Skipped), err
+		})
+	}, window)
+}
+
+// upload a local file or folder into the container directory
+func (c *FileCopy) upload(folder bool) {
+	remotePath, ok := c.getRemotePath()
+	window := c.getWindow()
+	if !ok || window == nil {
+		return
+	}
+	copyPath := func(localPath string) {
+		c.start(false, func(ctx context.Context, progress func(k8s.CopyProgress)) (string, error) {
+			written, err := k8s.CopyToContainer(ctx, c.client, c.config, c.podName, c.containerName, c.podNamespace,
+				localPath, remotePath, progress)
+			return getCopySummary("Uploaded", written, localPath, remotePath, nil), err
+		})
+	}
+	if folder {
+		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
+			if err != nil {
+				c.showError(err)
+			} else if uri != nil {
+				copyPath(uri.Path())
+			}
+		}, window)
+		return
+	}
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			c.showError(err)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		reader.Close()
+		copyPath(reader.URI().Path())
+	}, window)
+}
+
+// run a copy, showing its progress until it is done
+func (c *FileCopy) start(download bool, copyFiles func(ctx context.Context, progress func(k8s.CopyProgress)) (string, error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.mutex.Lock()
+	c.cancel()
+	c.cancel = cancel
+	c.progress = k8s.CopyProgress{}
+	c.mutex.Unlock()
+
+	for _, button := range c.buttons {
+		button.Disable()
+	}
+	c.cancelButton.Enable()
+	if download {
+		c.activityBar.Show()
+		c.activityBar.Start()
+	} else {
+		c.progressBar.SetValue(0)
+		c.progressBar.Show()
+	}
+	c.progressLabel.SetText("Starting copy...")
+
+	// progress is collected by the copy and shown in intervals
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		ticker := time.NewTicker(logFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.showProgress()
+			}
+		}
+	}()
+
+	go func() {
+		summary, err := copyFiles(ctx, func(progress k8s.CopyProgress) {
+			c.mutex.Lock()
+			c.progress = progress
+			c.mutex.Unlock()
+		})
+		// stop the progress before showing the summary, so it can't overwrite it
+		cancel()
+		<-progressDone
+
+		for _, button := range c.buttons {
+			button.Enable()
+		}
+		c.cancelButton.Disable()
+		c.activityBar.Stop()
+		c.activityBar.Hide()
+		c.progressBar.Hide()
+		switch {
+		case errors.Is(err, context.Canceled):
+			c.progressLabel.SetText("Copy canceled. " + summary)
+		case err != nil:
+			c.progressLabel.SetText("Copy failed. " + summary)
+			c.showError(err)
+		default:
+			c.progressLabel.SetText(summary)
+		}
+	}()
+}
+
+func (c *FileCopy) showProgress() {
+	c.mutex.Lock()
+	progress := c.progress
+	c.mutex.Unlock()
+
+	text := fmt.Sprintf("Copying: %d files, %s", progress.Files, utils.FormatBytes(progress.Bytes))
+	if progress.TotalBytes > 0 {
+		text += " of " + utils.FormatBytes(progress.TotalBytes)
+		c.progressBar.SetValue(float64(progress.Bytes) / float64(progress.TotalBytes))
+	}
+	c.progressLabel.SetText(text)
+}
+
+// summary of a copy, e.g. "Downloaded 3 files (1.5 KiB) from /data to /home/me", with the skipped entries
+func getCopySummary(action string, progress k8s.CopyProgress, from string, to string, skipped []string) string {
+	summary := fmt.Sprintf("%s %d files (%s) from %s to %s", action, progress.Files, utils.FormatBytes(progress.Bytes), from, to)
+	if len(skipped) > 0 {
+		summary += fmt.Sprintf(". Skipped %d unsafe paths, links and special files: %s", len(skipped), strings.Join(skipped, ", "))
+	}
+	return summary
+}
@@ -0,0 +1,212 @@
+package ui
+
+import (
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/michaeljsaenz/kview/internal/k8s"
+)
+
+// hint shown when the cluster can't be reached
+const kubeconfigHint = "Check the kubeconfig (--kubeconfig, $KUBECONFIG or ~/.kube/config), " +
+	"the selected context and your cluster credentials, then retry."
+
+// retry delay for throttled requests when the API server doesn't suggest one
+const throttledRetryDelay = 5 * time.Second
+
+// errors following each other closer than this are one failure burst, shown in one dialog
+const errorBurstWindow = 30 * time.Second
+
+// how an error class is shown to the user
+type errorTreatment struct {
+	title string
+	icon  fyne.Resource
+	hint  string
+	// interrupt with a dialog, otherwise the status bar only
+	dialog bool
+	// offer the retry action
+	retry bool
+}
+
+func getErrorTreatment(class k8s.ErrorClass) errorTreatment {
+	switch class {
+	case k8s.ErrorConfig:
+		return errorTreatment{"Kubeconfig Error", theme.SettingsIcon(), kubeconfigHint, true, true}
+	case k8s.ErrorAuth:
+		return errorTreatment{"Authentication Failed", theme.AccountIcon(),
+			"Your credentials were rejected or have expired. Log in again (for example aws sso login, " +
+				"gcloud auth login, az login or kubelogin) or refresh the token in the kubeconfig, then retry.", true, true}
+	case k8s.ErrorRBAC:
+		return errorTreatment{"Permission Denied", theme.WarningIcon(),
+			"Your user is not allowed to do this. Check your permissions with kubectl auth can-i, " +
+				"or ask a cluster admin for access.", false, false}
+	case k8s.ErrorNetwork:
+		return errorTreatment{"Cluster Unreachable", theme.ErrorIcon(),
+			"The API server could not be reached. Check your VPN, proxy and network connection, then retry.", true, true}
+	case k8s.ErrorNotFound:
+		return errorTreatment{"Not Found", theme.InfoIcon(),
+			"The resource no longer exists, it may have been deleted. Refresh the application (pod) list.", false, false}
+	case k8s.ErrorThrottled:
+		return errorTreatment{"Throttled", theme.HistoryIcon(),
+			"The API server is rate limiting requests, retrying shortly.", false, true}
+	}
+	return errorTreatment{"Error", theme.ErrorIcon(), "", false, true}
+}
+
+// StatusBar shows the last error of a session with a remediation hint and retry action.
+// Errors come from loaders, watches and timers, the state is guarded by mutex.
+type StatusBar struct {
+	Content *fyne.Container
+
+	win           fyne.Window
+	icon          *widget.Icon
+	label         *widget.Label
+	retryButton   *widget.Button
+	detailsButton *widget.Button
+
+	mutex      sync.Mutex
+	retry      func()
+	message    string
+	treatment  errorTreatment
+	retryTimer *time.Timer
+	// a dialog was shown for the current failure burst, which ended lastError
+	dialogShown bool
+	lastError   time.Time
+}
+
+func NewStatusBar(win fyne.Window) *StatusBar {
+	b := &StatusBar{win: win}
+	b.icon = widget.NewIcon(theme.ErrorIcon())
+	b.label = widget.NewLabel("")
+	b.label.TextStyle = fyne.TextStyle{Monospace: true}
+	b.label.Wrapping = fyne.TextTruncate
+
+	b.retryButton = widget.NewButtonWithIcon("Retry", theme.ViewRefreshIcon(), func() {
+		b.mutex.Lock()
+		retry := b.retry
+		b.mutex.Unlock()
+		if retry != nil {
+			b.Clear()
+			go retry()
+		}
+	})
+	b.detailsButton = widget.NewButtonWithIcon("Details", theme.InfoIcon(), func() {
+		b.showDialog()
+	})
+
+	b.Content = container.NewBorder(nil, nil, b.icon,
+		container.NewHBox(b.detailsButton, b.retryButton), b.label)
+	b.Content.Hide()
+	return b
+}
+
+// SetError shows the failed action with the treatment of its error class, retry is optional
+func (b *StatusBar) SetError(action string, err error, retry func()) {
+	b.setError(action, err, retry, false)
+}
+
+func (b *StatusBar) setError(action string, err error, retry func(), forceDialog bool) {
+	class := k8s.ClassifyError(err)
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.treatment = getErrorTreatment(class)
+	b.message = action + ": " + err.Error()
+	b.retry = nil
+	if b.treatment.retry {
+		b.retry = retry
+	}
+	if b.retryTimer != nil {
+		b.retryTimer.Stop()
+	}
+
+	b.icon.SetResource(b.treatment.icon)
+	if b.treatment.hint != "" {
+		b.label.SetText(b.treatment.title + " - " + b.message)
+	} else {
+		b.label.SetText(b.message)
+	}
+	if b.retry == nil {
+		b.retryButton.Hide()
+	} else {
+		b.retryButton.Show()
+	}
+	b.Content.Show()
+
+	// throttled requests are retried automatically
+	if class == k8s.ErrorThrottled && b.retry != nil {
+		delay := k8s.GetRetryDelay(err)
+		if delay == 0 {
+			delay = throttledRetryDelay
+		}
+		b.retryTimer = time.AfterFunc(delay, b.retryButton.OnTapped)
+	}
+
+	// one dialog per failure burst, e.g. a dropped VPN fails every pane and loader at once,
+	// the status bar carries the repeats
+	now := time.Now()
+	burst := b.dialogShown && now.Sub(b.lastError) < errorBurstWindow
+	b.lastError = now
+	if (b.treatment.dialog || forceDialog) && !burst {
+		b.dialogShown = true
+		b.openDialog()
+	}
+}
+
+func (b *StatusBar) showDialog() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.openDialog()
+}
+
+// show the current error in a dialog, mutex is held
+func (b *StatusBar) openDialog() {
+	var retry func()
+	if b.retry != nil {
+		retry = b.retryButton.OnTapped
+	}
+	ShowErrorDialog(b.treatment.title, b.message, b.treatment.hint, retry, b.win)
+}
+
+// Clear hides the error and ends the failure burst
+func (b *StatusBar) Clear() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.retryTimer != nil {
+		b.retryTimer.Stop()
+	}
+	b.message = ""
+	b.retry = nil
+	b.dialogShown = false
+	b.label.SetText("")
+	b.Content.Hide()
+}
+
+// ShowErrorDialog shows an error message with an optional hint and retry action
+func ShowErrorDialog(title string, message string, hint string, retry func(), win fyne.Window) {
+	errorLabel := widget.NewLabel(message)
+	errorLabel.TextStyle = fyne.TextStyle{Italic: true, Bold: true}
+	errorLabel.Wrapping = fyne.TextWrapBreak
+	content := container.NewVBox(errorLabel)
+	if hint != "" {
+		hintLabel := widget.NewLabel(hint)
+		hintLabel.Wrapping = fyne.TextWrapWord
+		content.Add(hintLabel)
+	}
+	scroll := container.NewVScroll(content)
+	scroll.SetMinSize(fyne.NewSize(500, 150))
+
+	if retry == nil {
+		dialog.ShowCustom(title, "Close", scroll, win)
+		return
+	}
+	dialog.ShowCustomConfirm(title, "Retry", "Close", scroll, func(confirmed bool) {
+		if confirmed {
+			retry()
+		}
+	}, win)
+}
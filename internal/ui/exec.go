@@ -0,0 +1,291 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/michaeljsaenz/kview/internal/k8s"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// command timeouts offered in the exec window
+var execTimeoutOptions = []string{"No timeout", "10s", "30s", "1m", "5m", "15m", "1h"}
+
+// ExecCommand runs one-shot commands in a container, streaming their stdout and stderr into a log view
+// while they run. A running command can be stopped, the timeout applies to every command of the window.
+type ExecCommand struct {
+	Content fyne.CanvasObject
+
+	client        kubernetes.Interface
+	config        rest.Config
+	podNamespace  string
+	podName       string
+	containerName string
+	showError     func(err error)
+
+	entry         *widget.Entry
+	timeoutSelect *widget.Select
+	runButton     *widget.Button
+	statusLabel   *widget.Label
+	outputView    *LogView
+
+	mutex   sync.Mutex
+	pending []string
+	running bool
+	cancel  context.CancelFunc
+}
+
+func NewExecCommand(client kubernetes.Interface, config rest.Config, podNamespace string, podName string,
+	containerName string, showError func(err error)) *ExecCommand {
+	e := &ExecCommand{client: client, config: config, podNamespace: podNamespace, podName: podName,
+		containerName: containerName, showError: showError, cancel: func() {}}
+
+	// Create an entry field for user input
+	e.entry = widget.NewEntry()
+	e.entry.SetPlaceHolder("Enter a command")
+	e.entry.OnSubmitted = func(string) { e.Run() }
+
+	// command output with its ANSI colors
+	e.outputView = NewLogView()
+	e.outputView.SetLineNumbers(false)
+
+	e.timeoutSelect = widget.NewSelect(execTimeoutOptions, nil)
+	e.timeoutSelect.Selected = execTimeoutOptions[0]
+	e.runButton = widget.NewButtonWithIcon("Run", theme.MediaPlayIcon(), func() {
+		if e.isRunning() {
+			e.Stop()
+		} else {
+			e.Run()
+		}
+	})
+	e.statusLabel = widget.NewLabel("")
+	e.statusLabel.Wrapping = fyne.TextTruncate
+
+	colorsCheck := widget.NewCheck("Colors", e.outputView.SetColors)
+	colorsCheck.Checked = true
+	copyButton := widget.NewButtonWithIcon("Copy Output", theme.ContentCopyIcon(), func() {
+		if window := getWindowForObject(e.outputView); window != nil {
+			window.Clipboard().SetContent(e.outputView.Text())
+		}
+	})
+	bottomBox := container.NewBorder(nil, nil, copyButton, colorsCheck, e.statusLabel)
+	commandBar := container.NewBorder(nil, nil, nil,
+		container.NewHBox(widget.NewLabel("Timeout"), e.timeoutSelect, e.runButton), e.entry)
+	e.Content = container.NewBorder(commandBar, bottomBox, nil, nil, e.outputView)
+	return e
+}
+
+func (e *ExecCommand) isRunning() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.running
+}
+
+func (e *ExecCommand) setRunning(running bool) {
+	e.mutex.Lock()
+	e.running = running
+	e.mutex.Unlock()
+	if running {
+		e.entry.Disable()
+		e.runButton.SetText("Stop")
+		e.runButton.SetIcon(theme.MediaStopIcon())
+	} else {
+		e.entry.Enable()
+		e.runButton.SetText("Run")
+		e.runButton.SetIcon(theme.MediaPlayIcon())
+	}
+}
+
+// Run runs the command of the entry, replacing the shown output
+func (e *ExecCommand) Run() {
+	command := e.entry.Text
+	if command == "" || e.isRunning() {
+		return
+	}
+	timeout, err := parseExecTimeout(e.timeoutSelect.Selected)
+	if err != nil {
+		e.showError(err)
+		return
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	e.mutex.Lock()
+	e.cancel()
+	e.cancel = cancel
+	e.pending = nil
+	e.mutex.Unlock()
+
+	e.outputView.SetText("")
+	e.statusLabel.SetText("Running " + command)
+	e.setRunning(true)
+
+	// output is collected by the streams and shown in batches
+	go func() {
+		ticker := time.NewTicker(logFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.flush()
+			}
+		}
+	}()
+
+	go func() {
+		stdout, stderr := newExecOutput(e.addLine, ""), newExecOutput(e.addLine, "\x1b[31m")
+		start := time.Now()
+		err := k8s.StreamExecCmd(ctx, e.client, e.config, e.podName, e.containerName, e.podNamespace, command, nil,
+			stdout, stderr)
+		cancel()
+		stdout.Close()
+		stderr.Close()
+		e.flush()
+		e.setRunning(false)
+		e.entry.SetText("") // clear the input field
+		e.statusLabel.SetText(getExecStatus(err, time.Since(start)))
+
+		// failed commands show their exit code, only errors running them are reported
+		_, exited := k8s.GetExitCode(err)
+		if err != nil && !exited && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+			e.showError(err)
+		}
+	}()
+}
+
+// Stop cancels the running command
+func (e *ExecCommand) Stop() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.cancel()
+}
+
+// collect an output line, at most the lines the view keeps
+func (e *ExecCommand) addLine(line string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.pending = append(e.pending, line)
+	if len(e.pending) > logViewCapacity {
+		e.pending = e.pending[1:]
+	}
+}
+
+// add the collected lines to the view, keeping it scrolled to the bottom unless scrolled up
+func (e *ExecCommand) flush() {
+	e.mutex.Lock()
+	pending := e.pending
+	e.pending = nil
+	e.mutex.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	atBottom := e.outputView.AtBottom()
+	e.outputView.AppendLines(pending)
+	if atBottom {
+		e.outputView.ScrollToBottom()
+	}
+}
+
+// status of a finished command, e.g. "Exit code 1 after 2.5s"
+func getExecStatus(err error, elapsed time.Duration) string {
+	elapsed = elapsed.Round(100 * time.Millisecond)
+	if exitCode, ok := k8s.GetExitCode(err); ok {
+		return fmt.Sprintf("Exit code %d after %v", exitCode, elapsed)
+	}
+	switch {
+	case err == nil:
+		return fmt.Sprintf("Exit code 0 after %v", elapsed)
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Sprintf("Timed out after %v", elapsed)
+	case errors.Is(err, context.Canceled):
+		return fmt.Sprintf("Stopped after %v", elapsed)
+	}
+	return "Failed"
+}
+
+// parse a timeout option, 0 for no timeout
+func parseExecTimeout(option string) (time.Duration, error) {
+	if option == execTimeoutOptions[0] {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(option)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q", option)
+	}
+	return timeout, nil
+}
+
+// longest output line, output without newlines (e.g. binary files) is cut into lines this long
+const maxExecLineLength = 64 << 10
+
+// execOutput splits the output stream of a command into lines, prefixed with an ANSI style
+// (e.g. red for stderr)
+type execOutput struct {
+	addLine func(line string)
+	style   string
+	mutex   sync.Mutex
+	partial []byte
+}
+
+func newExecOutput(addLine func(line string), style string) *execOutput {
+	return &execOutput{addLine: addLine, style: style}
+}
+
+func (o *execOutput) Write(data []byte) (int, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.partial = append(o.partial, data...)
+	for {
+		i := bytes.IndexByte(o.partial, '\n')
+		if i < 0 {
+			break
+		}
+		o.add(string(bytes.TrimSuffix(o.partial[:i], []byte("\r"))))
+		o.partial = o.partial[i+1:]
+	}
+	for len(o.partial) >= maxExecLineLength {
+		// don't cut a UTF-8 character in two
+		cut := maxExecLineLength
+		for cut > maxExecLineLength-utf8.UTFMax && !utf8.RuneStart(o.partial[cut]) {
+			cut--
+		}
+		o.add(string(o.partial[:cut]))
+		o.partial = o.partial[cut:]
+	}
+	return len(data), nil
+}
+
+// Close adds the last line when it didn't end with a newline
+func (o *execOutput) Close() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if len(o.partial) > 0 {
+		o.add(string(o.partial))
+		o.partial = nil
+	}
+}
+
+func (o *execOutput) add(line string) {
+	if o.style != "" {
+		line = o.style + line + "\x1b[0m"
+	}
+	o.addLine(line)
+}
@@ -0,0 +1,400 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/michaeljsaenz/kview/internal/k8s"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// commands running at once offered for fan-out exec
+var fanOutParallelismOptions = []string{"1", "2", "4", "8", "16", "32"}
+
+// FanOutExec runs a command in the same container of many pods at once, the pods are picked by a label
+// selector and pod name regex and can be checked off one by one. The results are shown per pod with
+// their exit code, or grouped by identical output to spot the pods that differ.
+type FanOutExec struct {
+	Content fyne.CanvasObject
+
+	client    kubernetes.Interface
+	config    rest.Config
+	namespace string
+	showError func(err error)
+
+	selectorEntry     *widget.Entry
+	podEntry          *widget.Entry
+	podChecks         *widget.CheckGroup
+	podsLabel         *widget.Label
+	containerSelect   *widget.Select
+	parallelismSelect *widget.Select
+	timeoutSelect     *widget.Select
+	commandEntry      *widget.Entry
+	runButton         *widget.Button
+	groupCheck        *widget.Check
+	statusLabel       *widget.Label
+	outputView        *LogView
+
+	mutex   sync.Mutex
+	pods    []corev1.Pod
+	targets []k8s.FanOutTarget
+	results []k8s.FanOutResult
+	done    []bool
+	// checked pods without the container
+	missing []string
+	running bool
+	cancel  context.CancelFunc
+}
+
+// ShowFanOutExec opens a window running commands in the pods of namespace, podPattern preselects the pods by name
+func ShowFanOutExec(app fyne.App, clientset kubernetes.Clientset, config rest.Config, namespace string, podPattern string) {
+	win := app.NewWindow("Exec in Pods: " + namespace)
+	f := NewFanOutExec(k8s.GetClientInterface(clientset), config, namespace, podPattern, func(err error) {
+		dialog.ShowError(err, win)
+	})
+	win.SetOnClosed(f.Stop)
+	win.SetContent(f.Content)
+	win.Resize(fyne.NewSize(1200, 800))
+	win.Show()
+	if podPattern != "" {
+		f.FindPods()
+	}
+}
+
+func NewFanOutExec(client kubernetes.Interface, config rest.Config, namespace string, podPattern string,
+	showError func(err error)) *FanOutExec {
+	f := &FanOutExec{client: client, config: config, namespace: namespace, showError: showError, cancel: func() {}}
+
+	f.selectorEntry = widget.NewEntry()
+	f.selectorEntry.SetPlaceHolder("Label selector: app=web,tier!=cache")
+	f.selectorEntry.OnSubmitted = func(string) { f.FindPods() }
+	f.podEntry = widget.NewEntry()
+	f.podEntry.SetPlaceHolder("Pod name regex: ^web-")
+	f.podEntry.SetText(podPattern)
+	f.podEntry.OnSubmitted = func(string) { f.FindPods() }
+	findButton := widget.NewButtonWithIcon("Find Pods", theme.SearchIcon(), f.FindPods)
+
+	f.podChecks = widget.NewCheckGroup(nil, func([]string) { f.updatePodsLabel() })
+	f.podChecks.Horizontal = true
+	f.podsLabel = widget.NewLabel("Find the pods to run the command in")
+	allButton := widget.NewButton("All", func() { f.podChecks.SetSelected(f.podChecks.Options) })
+	noneButton := widget.NewButton("None", func() { f.podChecks.SetSelected(nil) })
+	podScroll := container.NewVScroll(f.podChecks)
+	podScroll.SetMinSize(fyne.NewSize(0, 80))
+
+	f.containerSelect = widget.NewSelect(nil, nil)
+	f.containerSelect.PlaceHolder = "Container"
+	f.parallelismSelect = widget.NewSelect(fanOutParallelismOptions, nil)
+	f.parallelismSelect.Selected = "8"
+	f.timeoutSelect = widget.NewSelect(execTimeoutOptions, nil)
+	f.timeoutSelect.Selected = "30s"
+
+	f.commandEntry = widget.NewEntry()
+	f.commandEntry.SetPlaceHolder("Command, e.g. md5sum /etc/app/config.yaml")
+	f.commandEntry.OnSubmitted = func(string) { f.Run() }
+	f.runButton = widget.NewButtonWithIcon("Run", theme.MediaPlayIcon(), func() {
+		if f.isRunning() {
+			f.Stop()
+		} else {
+			f.Run()
+		}
+	})
+
+	f.outputView = NewLogView()
+	f.outputView.SetLineNumbers(false)
+	f.groupCheck = widget.NewCheck("Group identical outputs", func(bool) { f.showResults() })
+	f.statusLabel = widget.NewLabel("")
+	f.statusLabel.Wrapping = fyne.TextTruncate
+	copyButton := widget.NewButtonWithIcon("Copy Output", theme.ContentCopyIcon(), func() {
+		if window := getWindowForObject(f.outputView); window != nil {
+			window.Clipboard().SetContent(f.outputView.Text())
+		}
+	})
+
+	query := container.NewBorder(nil, nil, nil, findButton, container.NewGridWithColumns(2, f.selectorEntry, f.podEntry))
+	podBar := container.NewBorder(nil, nil, nil, container.NewHBox(allButton, noneButton), f.podsLabel)
+	commandBar := container.NewBorder(nil, nil, nil,
+		container.NewHBox(f.containerSelect, widget.NewLabel("Parallel"), f.parallelismSelect,
+			widget.NewLabel("Timeout"), f.timeoutSelect, f.runButton), f.commandEntry)
+	toolbar := container.NewVBox(query, podBar, podScroll, commandBar,
+		container.NewBorder(nil, nil, f.groupCheck, copyButton, f.statusLabel))
+	f.Content = container.NewBorder(toolbar, nil, nil, nil, f.outputView)
+	return f
+}
+
+// FindPods lists the running pods matching the selector and pod name regex, all of them checked
+func (f *FanOutExec) FindPods() {
+	var podPattern *regexp.Regexp
+	if pattern := strings.TrimSpace(f.podEntry.Text); pattern != "" {
+		var err error
+		if podPattern, err = regexp.Compile(pattern); err != nil {
+			f.showError(fmt.Errorf("invalid pod name regex %q: %w", pattern, err))
+			return
+		}
+	}
+	selector := strings.TrimSpace(f.selectorEntry.Text)
+	f.podsLabel.SetText("Finding pods...")
+	go func() {
+		pods, err := k8s.ListFanOutPods(context.Background(), f.client, f.namespace, selector, podPattern)
+		if err != nil {
+			f.podsLabel.SetText("Finding pods failed")
+			f.showError(err)
+			return
+		}
+		f.mutex.Lock()
+		f.pods = pods
+		f.mutex.Unlock()
+
+		names := make([]string, len(pods))
+		for i, pod := range pods {
+			names[i] = pod.Name
+		}
+		f.podChecks.Options = names
+		f.podChecks.SetSelected(names)
+		containers := k8s.GetFanOutContainers(pods)
+		f.containerSelect.Options = containers
+		if len(containers) > 0 && !containsString(containers, f.containerSelect.Selected) {
+			f.containerSelect.SetSelected(containers[0])
+		}
+		f.containerSelect.Refresh()
+		f.updatePodsLabel()
+	}()
+}
+
+func (f *FanOutExec) updatePodsLabel() {
+	f.podsLabel.SetText(fmt.Sprintf("%d of %d running pods selected", len(f.podChecks.Selected), len(f.podChecks.Options)))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FanOutExec) isRunning() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.running
+}
+
+func (f *FanOutExec) setRunning(running bool) {
+	f.mutex.Lock()
+	f.running = running
+	f.mutex.Unlock()
+	if running {
+		f.commandEntry.Disable()
+		f.runButton.SetText("Stop")
+		f.runButton.SetIcon(theme.MediaStopIcon())
+	} else {
+		f.commandEntry.Enable()
+		f.runButton.SetText("Run")
+		f.runButton.SetIcon(theme.MediaPlayIcon())
+	}
+}
+
+// Run runs the command in the container of the checked pods
+func (f *FanOutExec) Run() {
+	command := strings.TrimSpace(f.commandEntry.Text)
+	if command == "" || f.isRunning() {
+		return
+	}
+	timeout, err := parseExecTimeout(f.timeoutSelect.Selected)
+	if err != nil {
+		f.showError(err)
+		return
+	}
+	parallelism, _ := strconv.Atoi(f.parallelismSelect.Selected)
+
+	checked := make(map[string]bool)
+	for _, name := range f.podChecks.Selected {
+		checked[name] = true
+	}
+	f.mutex.Lock()
+	var pods []corev1.Pod
+	for _, pod := range f.pods {
+		if checked[pod.Name] {
+			pods = append(pods, pod)
+		}
+	}
+	f.mutex.Unlock()
+	if len(pods) == 0 {
+		f.showError(fmt.Errorf("no pods checked, find the pods to run the command in first"))
+		return
+	}
+	targets, missing := k8s.GetFanOutTargets(pods, f.containerSelect.Selected)
+	if len(targets) == 0 {
+		f.showError(fmt.Errorf("no checked pod has container %q", f.containerSelect.Selected))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f.mutex.Lock()
+	f.cancel()
+	f.cancel = cancel
+	f.targets = targets
+	f.missing = missing
+	f.results = make([]k8s.FanOutResult, len(targets))
+	f.done = make([]bool, len(targets))
+	f.mutex.Unlock()
+
+	f.setRunning(true)
+	f.showResults()
+	go func() {
+		k8s.FanOutExec(ctx, f.client, f.config, f.namespace, targets, command, parallelism, timeout,
+			func(index int, result k8s.FanOutResult) {
+				f.mutex.Lock()
+				f.results[index], f.done[index] = result, true
+				f.mutex.Unlock()
+				f.showResults()
+			})
+		cancel()
+		f.setRunning(false)
+		f.showResults()
+	}()
+}
+
+// Stop cancels the running commands, commands not started yet are not run
+func (f *FanOutExec) Stop() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.cancel()
+}
+
+// show the results so far per pod or grouped
+func (f *FanOutExec) showResults() {
+	f.mutex.Lock()
+	var finished []k8s.FanOutResult
+	for i, result := range f.results {
+		if f.done[i] {
+			finished = append(finished, result)
+		}
+	}
+	targets := append([]k8s.FanOutTarget(nil), f.targets...)
+	done := append([]bool(nil), f.done...)
+	missing := f.missing
+	running := f.running
+	f.mutex.Unlock()
+
+	var lines []string
+	if f.groupCheck.Checked {
+		lines = formatFanOutGroups(k8s.GroupFanOutResults(finished))
+	} else {
+		lines = formatFanOutResults(finished)
+	}
+	for i, target := range targets {
+		if !done[i] && running {
+			lines = append(lines, "\x1b[2m▶ "+target.String()+" running...\x1b[0m")
+		}
+	}
+	f.outputView.SetLines(lines)
+	status := getFanOutStatus(finished, len(targets))
+	if len(missing) > 0 {
+		status += fmt.Sprintf(", skipped %s without container %s: %s", getPodCount(len(missing)), targets[0].Container,
+			strings.Join(missing, ", "))
+	}
+	f.statusLabel.SetText(status)
+}
+
+// a header line and the output of every result
+func formatFanOutResults(results []k8s.FanOutResult) []string {
+	var lines []string
+	for _, result := range results {
+		header := fmt.Sprintf("▶ %s  %s  %v", result.Target, getFanOutExit(result.ExitCode, errorText(result.Err)),
+			result.Duration.Round(100*time.Millisecond))
+		lines = append(lines, getFanOutColor(result.ExitCode, false)+header+"\x1b[0m")
+		lines = append(lines, getFanOutOutputLines(result.Output)...)
+	}
+	return lines
+}
+
+// a header line with the pods of every group and its output, groups smaller than the first are outliers
+func formatFanOutGroups(groups []k8s.FanOutGroup) []string {
+	var lines []string
+	for i, group := range groups {
+		names := make([]string, len(group.Targets))
+		for j, target := range group.Targets {
+			names[j] = target.Pod
+		}
+		outlier := i > 0 && len(group.Targets) < len(groups[0].Targets)
+		header := fmt.Sprintf("▶ %s  %s", getPodCount(len(group.Targets)), getFanOutExit(group.ExitCode, group.Error))
+		if outlier {
+			header += "  outlier"
+		}
+		header += ": " + strings.Join(names, ", ")
+		lines = append(lines, getFanOutColor(group.ExitCode, outlier)+header+"\x1b[0m")
+		lines = append(lines, getFanOutOutputLines(group.Output)...)
+	}
+	return lines
+}
+
+// e.g. "1 pod" or "3 pods"
+func getPodCount(count int) string {
+	if count == 1 {
+		return "1 pod"
+	}
+	return strconv.Itoa(count) + " pods"
+}
+
+func getFanOutExit(exitCode int, errorMessage string) string {
+	if errorMessage != "" {
+		return "error: " + errorMessage
+	}
+	return "exit " + strconv.Itoa(exitCode)
+}
+
+// bold green for exit code 0, red for failures and yellow for outliers
+func getFanOutColor(exitCode int, outlier bool) string {
+	switch {
+	case exitCode != 0:
+		return "\x1b[1;31m"
+	case outlier:
+		return "\x1b[1;33m"
+	}
+	return "\x1b[1;32m"
+}
+
+// the lines of an output followed by an empty line
+func getFanOutOutputLines(output string) []string {
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		return []string{"\x1b[2m(no output)\x1b[0m", ""}
+	}
+	return append(strings.Split(output, "\n"), "")
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// status of the results, e.g. "4 of 5 pods done: 3 exit 0, 1 failed, 2 distinct outputs"
+func getFanOutStatus(results []k8s.FanOutResult, total int) string {
+	if total == 0 {
+		return ""
+	}
+	succeeded := 0
+	for _, result := range results {
+		if result.ExitCode == 0 {
+			succeeded++
+		}
+	}
+	return fmt.Sprintf("%d of %d pods done: %d exit 0, %d failed, %d distinct outputs", len(results), total, succeeded,
+		len(results)-succeeded, len(k8s.GroupFanOutResults(results)))
+}
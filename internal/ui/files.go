@@ -0,0 +1,325 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/michaeljsaenz/kview/internal/k8s"
+	"github.com/michaeljsaenz/kview/internal/utils"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// bytes of a file shown in the viewer, larger files are cut
+const fileViewLimit = 1 << 20
+
+// FileBrowser browses the files of a container with exec: a directory tree with size, mode and
+// modification time loaded as directories are opened, a read-only viewer for text files and downloads.
+type FileBrowser struct {
+	Content fyne.CanvasObject
+	// called with the path of the selected file or directory
+	OnSelected func(filePath string)
+
+	client        kubernetes.Interface
+	config        rest.Config
+	podNamespace  string
+	podName       string
+	containerName string
+	showError     func(err error)
+
+	tree           *widget.Tree
+	pathEntry      *widget.Entry
+	statusLabel    *widget.Label
+	fileLabel      *widget.Label
+	downloadButton *widget.Button
+	viewer         *LogView
+
+	mutex sync.Mutex
+	root  string
+	// entries by path and the paths in loaded directories
+	entries  map[string]k8s.FileEntry
+	children map[string][]string
+	loading  map[string]bool
+	selected string
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func NewFileBrowser(client kubernetes.Interface, config rest.Config, podNamespace string, podName string,
+	containerName string, showError func(err error)) *FileBrowser {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &FileBrowser{client: client, config: config, podNamespace: podNamespace, podName: podName,
+		containerName: containerName, showError: showError, root: "/", entries: make(map[string]k8s.FileEntry),
+		children: make(map[string][]string), loading: make(map[string]bool), ctx: ctx, cancel: cancel}
+
+	b.tree = widget.NewTree(b.getChildren, b.isDirectory,
+		func(bool) fyne.CanvasObject {
+			details := widget.NewLabel("")
+			details.TextStyle = fyne.TextStyle{Monospace: true}
+			name := widget.NewLabel("")
+			name.Wrapping = fyne.TextTruncate
+			return container.NewBorder(nil, nil, widget.NewIcon(nil), details, name)
+		},
+		b.updateNode)
+	b.tree.OnSelected = b.selectPath
+
+	b.pathEntry = widget.NewEntry()
+	b.pathEntry.SetText(b.root)
+	b.pathEntry.OnSubmitted = func(text string) { b.SetRoot(text) }
+	upButton := widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() {
+		b.SetRoot(path.Dir(b.getRoot()))
+	})
+	refreshButton := widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), b.Refresh)
+	b.statusLabel = widget.NewLabel("")
+	b.statusLabel.Wrapping = fyne.TextWrapWord
+	b.statusLabel.Hide()
+
+	b.viewer = NewLogView()
+	b.fileLabel = widget.NewLabel("Select a file to view it")
+	b.fileLabel.Wrapping = fyne.TextTruncate
+	b.downloadButton = widget.NewButtonWithIcon("Download", theme.DownloadIcon(), b.download)
+	b.downloadButton.Disable()
+
+	toolbar := container.NewVBox(container.NewBorder(nil, nil, upButton, refreshButton, b.pathEntry), b.statusLabel)
+	viewer := container.NewBorder(container.NewBorder(nil, nil, nil, b.downloadButton, b.fileLabel), nil, nil, nil, b.viewer)
+	split := container.NewHSplit(b.tree, viewer)
+	split.Offset = 0.5
+	b.Content = container.NewBorder(toolbar, nil, nil, nil, split)
+	return b
+}
+
+// Close stops loading directories and files
+func (b *FileBrowser) Close() {
+	b.cancel()
+}
+
+func (b *FileBrowser) getRoot() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.root
+}
+
+// SetRoot shows the tree of a directory
+func (b *FileBrowser) SetRoot(directory string) {
+	directory = path.Clean("/" + strings.TrimSpace(directory))
+	b.mutex.Lock()
+	b.root = directory
+	b.mutex.Unlock()
+	b.pathEntry.SetText(directory)
+	b.statusLabel.Hide()
+	b.tree.UnselectAll()
+	b.tree.Refresh()
+}
+
+// Refresh loads the directories again
+func (b *FileBrowser) Refresh() {
+	b.mutex.Lock()
+	b.children = make(map[string][]string)
+	b.mutex.Unlock()
+	b.statusLabel.Hide()
+	b.tree.Refresh()
+}
+
+// children of a directory node, a directory not loaded yet is loaded and shown when done.
+// The hidden root node of the tree is the root directory.
+func (b *FileBrowser) getChildren(uid widget.TreeNodeID) []widget.TreeNodeID {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if uid == "" {
+		uid = b.root
+	}
+	if children, ok := b.children[uid]; ok {
+		return children
+	}
+	if !b.loading[uid] {
+		b.loading[uid] = true
+		go b.load(uid)
+	}
+	return nil
+}
+
+func (b *FileBrowser) isDirectory(uid widget.TreeNodeID) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return uid == "" || b.entries[uid].IsDirectory()
+}
+
+func (b *FileBrowser) load(directory string) {
+	entries, err := k8s.ListDirectory(b.ctx, b.client, b.config, b.podName, b.containerName, b.podNamespace, directory)
+	if b.ctx.Err() != nil {
+		return
+	}
+
+	children := make([]string, len(entries))
+	b.mutex.Lock()
+	for i, entry := range entries {
+		b.entries[entry.Path] = entry
+		children[i] = entry.Path
+	}
+	// a failed directory stays empty until refreshed
+	b.children[directory] = children
+	delete(b.loading, directory)
+	b.mutex.Unlock()
+
+	if err != nil {
+		b.statusLabel.SetText(getFileBrowserError(err))
+		b.statusLabel.Show()
+	}
+	b.tree.Refresh()
+}
+
+// explain a failed listing, a container without shell or ls can't be browsed at all
+func getFileBrowserError(err error) string {
+	if errors.Is(err, k8s.ErrFilesUnavailable) {
+		return "This container has no shell or ls (e.g. a distroless image), its files can't be browsed. " +
+			"Start an ephemeral debug container (kubectl debug) sharing its process namespace to look at them " +
+			"under /proc/1/root."
+	}
+	return err.Error()
+}
+
+func (b *FileBrowser) updateNode(uid widget.TreeNodeID, _ bool, node fyne.CanvasObject) {
+	b.mutex.Lock()
+	entry := b.entries[uid]
+	b.mutex.Unlock()
+
+	objects := node.(*fyne.Container).Objects
+	name, icon, details := objects[0].(*widget.Label), objects[1].(*widget.Icon), objects[2].(*widget.Label)
+	name.SetText(getFileEntryName(entry))
+	details.SetText(getFileEntryDetails(entry))
+	switch entry.Type {
+	case k8s.FileDirectory:
+		icon.SetResource(theme.FolderIcon())
+	case k8s.FileSymlink:
+		icon.SetResource(theme.MailForwardIcon())
+	case k8s.FileRegular:
+		icon.SetResource(theme.FileIcon())
+	default:
+		icon.SetResource(theme.QuestionIcon())
+	}
+}
+
+// name of an entry with its link target, e.g. "current -> releases/v2"
+func getFileEntryName(entry k8s.FileEntry) string {
+	if entry.LinkTarget != "" {
+		return entry.Name + " -> " + entry.LinkTarget
+	}
+	return entry.Name
+}
+
+// mode, size and modification time of an entry, e.g. "-rw-r--r--   1.2 KiB 2024-01-02 15:04"
+func getFileEntryDetails(entry k8s.FileEntry) string {
+	modTime := "                "
+	if !entry.ModTime.IsZero() {
+		modTime = entry.ModTime.Local().Format("2006-01-02 15:04")
+	}
+	return fmt.Sprintf("%s %10s %s", entry.Mode, utils.FormatBytes(entry.Size), modTime)
+}
+
+func (b *FileBrowser) selectPath(uid widget.TreeNodeID) {
+	b.mutex.Lock()
+	entry := b.entries[uid]
+	b.selected = uid
+	b.mutex.Unlock()
+	if b.OnSelected != nil {
+		b.OnSelected(uid)
+	}
+
+	if entry.IsDirectory() {
+		b.tree.OpenBranch(uid)
+		b.downloadButton.Disable()
+		b.fileLabel.SetText(uid + " is a directory, download it with the copy bar above")
+		b.viewer.SetText("")
+		return
+	}
+	if entry.Type == k8s.FileOther {
+		b.downloadButton.Disable()
+		b.fileLabel.SetText(uid + " is not a regular file")
+		b.viewer.SetText("")
+		return
+	}
+	b.downloadButton.Enable()
+	b.open(uid)
+}
+
+// show the start of a text file in the viewer
+func (b *FileBrowser) open(filePath string) {
+	b.fileLabel.SetText("Loading " + filePath + "...")
+	b.viewer.SetText("")
+	go func() {
+		content, truncated, err := k8s.ReadFile(b.ctx, b.client, b.config, b.podName, b.containerName, b.podNamespace,
+			filePath, fileViewLimit)
+		b.mutex.Lock()
+		current := b.selected == filePath
+		b.mutex.Unlock()
+		// another file was selected meanwhile
+		if !current || b.ctx.Err() != nil {
+			return
+		}
+
+		switch {
+		case err != nil:
+			b.fileLabel.SetText(filePath)
+			b.viewer.SetText(getFileBrowserError(err))
+		case !k8s.IsTextFile(content):
+			b.fileLabel.SetText(filePath + " is a binary file, download it to open it")
+		case truncated:
+			b.fileLabel.SetText(fmt.Sprintf("%s (first %s shown, download it for all)", filePath, utils.FormatBytes(fileViewLimit)))
+			b.viewer.SetText(string(content))
+		default:
+			b.fileLabel.SetText(fmt.Sprintf("%s (%s)", filePath, utils.FormatBytes(int64(len(content)))))
+			b.viewer.SetText(string(content))
+		}
+	}()
+}
+
+// save the selected file with a file dialog
+func (b *FileBrowser) download() {
+	b.mutex.Lock()
+	filePath := b.selected
+	b.mutex.Unlock()
+	window := getWindowForObject(b.tree)
+	if filePath == "" || window == nil {
+		return
+	}
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			b.showError(err)
+			return
+		}
+		// canceled
+		if writer == nil {
+			return
+		}
+		b.downloadButton.Disable()
+		go func() {
+			defer b.downloadButton.Enable()
+			size, err := k8s.DownloadFile(b.ctx, b.client, b.config, b.podName, b.containerName, b.podNamespace,
+				filePath, writer)
+			if closeErr := writer.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				// don't leave a partial file behind
+				storage.Delete(writer.URI())
+				if b.ctx.Err() == nil {
+					b.showError(err)
+				}
+				return
+			}
+			b.fileLabel.SetText(fmt.Sprintf("Downloaded %s (%s) to %s", filePath, utils.FormatBytes(size), writer.URI().Path()))
+		}()
+	}, window)
+	saveDialog.SetFileName(path.Base(filePath))
+	saveDialog.Show()
+}
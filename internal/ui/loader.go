@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"context"
+	"sync"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+// Loader runs Kubernetes API calls off the UI goroutine for one selection (a namespace or a pod).
+// A new selection cancels the calls of the previous one and drops their results,
+// so a slow response never overwrites a newer selection.
+type Loader struct {
+	// called with true when the first call starts and with false when the last one ends
+	OnBusy func(busy bool)
+
+	mutex   sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pending int
+}
+
+func NewLoader(onBusy func(busy bool)) *Loader {
+	l := &Loader{OnBusy: onBusy}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	return l
+}
+
+// Reset cancels the calls of the previous selection and returns the context of the new one
+func (l *Loader) Reset() context.Context {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.cancel()
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	return l.ctx
+}
+
+// Cancel cancels the calls of the current selection
+func (l *Loader) Cancel() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.cancel()
+}
+
+// Run calls load in a goroutine, the function returned by load updates the widgets
+// and is skipped when ctx was canceled in the meantime
+func (l *Loader) Run(ctx context.Context, load func(ctx context.Context) func()) {
+	l.setPending(1)
+	go func() {
+		defer l.setPending(-1)
+		apply := load(ctx)
+		if apply == nil || ctx.Err() != nil {
+			return
+		}
+		apply()
+	}()
+}
+
+func (l *Loader) setPending(delta int) {
+	l.mutex.Lock()
+	l.pending += delta
+	busyChanged := (delta > 0 && l.pending == 1) || (delta < 0 && l.pending == 0)
+	busy := l.pending > 0
+	l.mutex.Unlock()
+	if busyChanged && l.OnBusy != nil {
+		l.OnBusy(busy)
+	}
+}
+
+// create a hidden activity bar that is shown while a loader is busy
+func CreateActivity() (*widget.ProgressBarInfinite, func(busy bool)) {
+	activity := widget.NewProgressBarInfinite()
+	activity.Stop()
+	activity.Hide()
+	return activity, func(busy bool) {
+		if busy {
+			activity.Show()
+			activity.Start()
+		} else {
+			activity.Stop()
+			activity.Hide()
+		}
+	}
+}
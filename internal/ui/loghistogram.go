@@ -0,0 +1,416 @@
+package ui
+
+import (
+	"image/color"
+	"strconv"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	// height of the histogram bars and their time axis
+	logHistogramHeight = 56
+	// width of a bucket, the number of buckets follows the width of the histogram
+	logHistogramBarWidth = 6
+)
+
+// logPoint is a log line with a timestamp
+type logPoint struct {
+	time  time.Time
+	line  int
+	error bool
+}
+
+// LogHistogram shows the number of log lines over time, error lines are highlighted. Clicking a bar
+// or dragging over bars zooms into that time window and OnZoom gets the lines in it. Lines need the
+// timestamps of the Timestamps log option, without them a hint offers to turn them on.
+type LogHistogram struct {
+	Content fyne.CanvasObject
+
+	// called with the first and last line number of the zoomed time window, 0 and 0 when zoomed out
+	OnZoom func(first int, last int)
+	// called to turn on the timestamps of the log lines
+	OnTimestamps func()
+
+	chart       *logHistogramChart
+	body        *fyne.Container
+	hint        fyne.CanvasObject
+	rangeLabel  *widget.Label
+	resetButton *widget.Button
+
+	mutex    sync.RWMutex
+	points   []logPoint
+	lines    int
+	from, to time.Time
+	zoomed   bool
+}
+
+func NewLogHistogram() *LogHistogram {
+	h := &LogHistogram{}
+	h.chart = &logHistogramChart{histogram: h}
+	h.chart.ExtendBaseWidget(h.chart)
+
+	h.rangeLabel = widget.NewLabel("")
+	h.resetButton = widget.NewButtonWithIcon("", theme.ZoomOutIcon(), h.Reset)
+	h.resetButton.Disable()
+	h.hint = container.NewHBox(widget.NewLabel("Log lines have no timestamps, the histogram needs them"),
+		widget.NewButton("Show timestamps", func() {
+			if h.OnTimestamps != nil {
+				h.OnTimestamps()
+			}
+		}))
+	h.body = container.NewMax(h.chart)
+	h.Content = container.NewBorder(nil, nil, nil, container.NewHBox(h.rangeLabel, h.resetButton), h.body)
+	return h
+}
+
+// SetLines replaces the lines, zooming out
+func (h *LogHistogram) SetLines(lines []string) {
+	h.mutex.Lock()
+	h.points, h.lines, h.zoomed = nil, 0, false
+	h.mutex.Unlock()
+	h.resetButton.Disable()
+	h.AppendLines(lines)
+}
+
+// AppendLines adds lines at the end, the oldest lines are dropped like in the log view.
+// A zoomed histogram keeps its time window.
+func (h *LogHistogram) AppendLines(lines []string) {
+	points := make([]logPoint, 0, len(lines))
+	h.mutex.RLock()
+	lineNumber := h.lines
+	h.mutex.RUnlock()
+	for _, line := range lines {
+		lineNumber++
+		if lineTime, isError, ok := getLogLineTime(line); ok {
+			points = append(points, logPoint{time: lineTime, line: lineNumber, error: isError})
+		}
+	}
+
+	h.mutex.Lock()
+	h.lines += len(lines)
+	h.points = append(h.points, points...)
+	// drop the points of lines dropped by the log view
+	dropped := 0
+	for dropped < len(h.points) && h.points[dropped].line <= h.lines-logViewCapacity {
+		dropped++
+	}
+	if dropped > 0 {
+		h.points = append([]logPoint(nil), h.points[dropped:]...)
+	}
+	if !h.zoomed {
+		h.from, h.to = getLogPointsSpan(h.points)
+	}
+	hasPoints, hasLines := len(h.points) > 0, h.lines > 0
+	h.mutex.Unlock()
+
+	// the hint replaces the chart when lines have no timestamps
+	if !hasPoints && hasLines {
+		h.body.Objects = []fyne.CanvasObject{h.hint}
+	} else {
+		h.body.Objects = []fyne.CanvasObject{h.chart}
+	}
+	h.body.Refresh()
+	h.updateLabel()
+	h.chart.Refresh()
+}
+
+// Reset zooms out to all lines
+func (h *LogHistogram) Reset() {
+	h.mutex.Lock()
+	h.zoomed = false
+	h.from, h.to = getLogPointsSpan(h.points)
+	h.mutex.Unlock()
+	h.resetButton.Disable()
+	h.updateLabel()
+	h.chart.Refresh()
+	if h.OnZoom != nil {
+		h.OnZoom(0, 0)
+	}
+}
+
+// zoom into the time window from to, the log shows the lines from the first to the last line in it
+func (h *LogHistogram) zoom(from time.Time, to time.Time) {
+	h.mutex.Lock()
+	first, last := 0, 0
+	for _, point := range h.points {
+		if point.time.Before(from) || point.time.After(to) {
+			continue
+		}
+		if first == 0 || point.line < first {
+			first = point.line
+		}
+		if point.line > last {
+			last = point.line
+		}
+	}
+	// nothing to show in the window
+	if first == 0 {
+		h.mutex.Unlock()
+		return
+	}
+	h.from, h.to, h.zoomed = from, to, true
+	h.mutex.Unlock()
+
+	h.resetButton.Enable()
+	h.updateLabel()
+	h.chart.Refresh()
+	if h.OnZoom != nil {
+		h.OnZoom(first, last)
+	}
+}
+
+// show the time window and its number of lines and errors
+func (h *LogHistogram) updateLabel() {
+	h.mutex.RLock()
+	lines, errors := 0, 0
+	for _, point := range h.points {
+		if !point.time.Before(h.from) && !point.time.After(h.to) {
+			lines++
+			if point.error {
+				errors++
+			}
+		}
+	}
+	from, to, hasPoints := h.from, h.to, len(h.points) > 0
+	h.mutex.RUnlock()
+
+	if !hasPoints {
+		h.rangeLabel.SetText("")
+		return
+	}
+	h.rangeLabel.SetText(formatLogTime(from, to, from) + " – " + formatLogTime(from, to, to) + ", " +
+		strconv.Itoa(lines) + " lines, " + strconv.Itoa(errors) + " errors")
+}
+
+// count the lines and error lines in buckets over the time window, also returns the length of a bucket
+func (h *LogHistogram) getBuckets(buckets int) ([]int, []int, time.Duration) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	totals, errors := make([]int, buckets), make([]int, buckets)
+	span := h.to.Sub(h.from)
+	bucketSpan := span/time.Duration(buckets) + 1
+	for _, point := range h.points {
+		if point.time.Before(h.from) || point.time.After(h.to) {
+			continue
+		}
+		bucket := int(point.time.Sub(h.from) / bucketSpan)
+		if bucket >= buckets {
+			bucket = buckets - 1
+		}
+		totals[bucket]++
+		if point.error {
+			errors[bucket]++
+		}
+	}
+	return totals, errors, bucketSpan
+}
+
+// time at x of a chart of width
+func (h *LogHistogram) getTimeAt(x float32, width float32) time.Time {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if width <= 0 {
+		return h.from
+	}
+	x = fyne.Max(0, fyne.Min(x, width))
+	return h.from.Add(time.Duration(float64(h.to.Sub(h.from)) * float64(x/width)))
+}
+
+// the time of the first and last point, log lines aren't always in order
+func getLogPointsSpan(points []logPoint) (time.Time, time.Time) {
+	var from, to time.Time
+	for i, point := range points {
+		if i == 0 || point.time.Before(from) {
+			from = point.time
+		}
+		if i == 0 || point.time.After(to) {
+			to = point.time
+		}
+	}
+	return from, to
+}
+
+// format a time of the window from to, with the date when the window spans days
+func formatLogTime(from time.Time, to time.Time, t time.Time) string {
+	from, to, t = from.Local(), to.Local(), t.Local()
+	if from.YearDay() != to.YearDay() || from.Year() != to.Year() {
+		return t.Format("01-02 15:04:05")
+	}
+	return t.Format("15:04:05")
+}
+
+// logHistogramChart draws the bars of a LogHistogram, clicks and drags select a time window
+type logHistogramChart struct {
+	widget.BaseWidget
+	histogram *LogHistogram
+
+	// x of the dragged selection, dragging when dragging is true
+	dragStart, dragEnd float32
+	dragging           bool
+}
+
+func (c *logHistogramChart) CreateRenderer() fyne.WidgetRenderer {
+	r := &logHistogramRenderer{chart: c, selection: canvas.NewRectangle(color.Transparent),
+		fromText: canvas.NewText("", theme.ForegroundColor()), toText: canvas.NewText("", theme.ForegroundColor())}
+	r.fromText.TextSize = theme.CaptionTextSize()
+	r.toText.TextSize = theme.CaptionTextSize()
+	r.toText.Alignment = fyne.TextAlignTrailing
+	return r
+}
+
+// zoom into the bucket clicked
+func (c *logHistogramChart) Tapped(e *fyne.PointEvent) {
+	width := c.Size().Width
+	buckets := getLogHistogramBuckets(width)
+	bucketWidth := width / float32(buckets)
+	bucket := int(e.Position.X / bucketWidth)
+	c.histogram.zoom(c.histogram.getTimeAt(float32(bucket)*bucketWidth, width),
+		c.histogram.getTimeAt(float32(bucket+1)*bucketWidth, width))
+}
+
+func (c *logHistogramChart) Dragged(e *fyne.DragEvent) {
+	if !c.dragging {
+		c.dragStart, c.dragging = e.Position.X-e.Dragged.DX, true
+	}
+	c.dragEnd = e.Position.X
+	c.Refresh()
+}
+
+// zoom into the dragged time window
+func (c *logHistogramChart) DragEnd() {
+	if !c.dragging {
+		return
+	}
+	c.dragging = false
+	start, end := c.dragStart, c.dragEnd
+	if end < start {
+		start, end = end, start
+	}
+	width := c.Size().Width
+	c.Refresh()
+	c.histogram.zoom(c.histogram.getTimeAt(start, width), c.histogram.getTimeAt(end, width))
+}
+
+func getLogHistogramBuckets(width float32) int {
+	buckets := int(width / logHistogramBarWidth)
+	if buckets < 1 {
+		return 1
+	}
+	return buckets
+}
+
+type logHistogramRenderer struct {
+	chart     *logHistogramChart
+	bars      []*canvas.Rectangle
+	errorBars []*canvas.Rectangle
+	selection *canvas.Rectangle
+	fromText  *canvas.Text
+	toText    *canvas.Text
+	objects   []fyne.CanvasObject
+}
+
+func (r *logHistogramRenderer) Layout(fyne.Size) {
+	r.update()
+}
+
+func (r *logHistogramRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(logHistogramBarWidth*10, logHistogramHeight)
+}
+
+func (r *logHistogramRenderer) Refresh() {
+	r.update()
+	canvas.Refresh(r.chart)
+}
+
+func (r *logHistogramRenderer) Objects() []fyne.CanvasObject {
+	return r.objects
+}
+
+func (r *logHistogramRenderer) Destroy() {
+}
+
+// draw a bar per bucket with its error lines at the bottom, and the time axis below
+func (r *logHistogramRenderer) update() {
+	size := r.chart.Size()
+	h := r.chart.histogram
+	buckets := getLogHistogramBuckets(size.Width)
+	totals, errors, _ := h.getBuckets(buckets)
+
+	h.mutex.RLock()
+	from, to, hasPoints := h.from, h.to, len(h.points) > 0
+	h.mutex.RUnlock()
+	if hasPoints {
+		r.fromText.Text, r.toText.Text = formatLogTime(from, to, from), formatLogTime(from, to, to)
+	} else {
+		r.fromText.Text, r.toText.Text = "", ""
+	}
+	r.fromText.Color, r.toText.Color = theme.ForegroundColor(), theme.ForegroundColor()
+	textHeight := r.fromText.MinSize().Height
+	r.fromText.Move(fyne.NewPos(0, size.Height-textHeight))
+	r.fromText.Resize(fyne.NewSize(size.Width/2, textHeight))
+	r.toText.Move(fyne.NewPos(size.Width/2, size.Height-textHeight))
+	r.toText.Resize(fyne.NewSize(size.Width/2, textHeight))
+
+	highest := 1
+	for _, total := range totals {
+		if total > highest {
+			highest = total
+		}
+	}
+	for len(r.bars) < buckets {
+		r.bars = append(r.bars, canvas.NewRectangle(color.Transparent))
+		r.errorBars = append(r.errorBars, canvas.NewRectangle(color.Transparent))
+	}
+	barsHeight := size.Height - textHeight
+	bucketWidth := size.Width / float32(buckets)
+	barColor, errorColor := withAlpha(theme.PrimaryColor(), 0x90), theme.ErrorColor()
+	for i, bar := range r.bars {
+		errorBar := r.errorBars[i]
+		if i >= buckets || totals[i] == 0 {
+			bar.Hide()
+			errorBar.Hide()
+			continue
+		}
+		x := float32(i) * bucketWidth
+		height := fyne.Max(1, barsHeight*float32(totals[i])/float32(highest))
+		bar.FillColor = barColor
+		bar.Move(fyne.NewPos(x, barsHeight-height))
+		bar.Resize(fyne.NewSize(fyne.Max(1, bucketWidth-1), height))
+		bar.Show()
+		if errors[i] == 0 {
+			errorBar.Hide()
+			continue
+		}
+		errorHeight := fyne.Max(1, barsHeight*float32(errors[i])/float32(highest))
+		errorBar.FillColor = errorColor
+		errorBar.Move(fyne.NewPos(x, barsHeight-errorHeight))
+		errorBar.Resize(fyne.NewSize(fyne.Max(1, bucketWidth-1), errorHeight))
+		errorBar.Show()
+	}
+
+	if r.chart.dragging {
+		start, end := r.chart.dragStart, r.chart.dragEnd
+		if end < start {
+			start, end = end, start
+		}
+		r.selection.FillColor = withAlpha(theme.PrimaryColor(), 0x40)
+		r.selection.Move(fyne.NewPos(start, 0))
+		r.selection.Resize(fyne.NewSize(end-start, barsHeight))
+		r.selection.Show()
+	} else {
+		r.selection.Hide()
+	}
+
+	r.objects = r.objects[:0]
+	for i := range r.bars {
+		r.objects = append(r.objects, r.bars[i], r.errorBars[i])
+	}
+	r.objects = append(r.objects, r.selection, r.fromText, r.toText)
+}
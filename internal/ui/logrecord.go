@@ -0,0 +1,323 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+	"github.com/michaeljsaenz/kview/internal/utils"
+)
+
+// field names used for the time, level and message of structured log lines, in order of preference
+var (
+	logTimeKeys    = []string{"time", "ts", "timestamp", "@timestamp", "t", "date"}
+	logLevelKeys   = []string{"level", "lvl", "severity", "loglevel", "log.level", "@level", "levelname"}
+	logMessageKeys = []string{"msg", "message", "@message", "log", "text"}
+)
+
+// error words of plain text lines, and the E/F severity of klog lines like "E0102 15:04:05.000000 ..."
+var plainLogErrorPattern = regexp.MustCompile(`(?i)\b(error|fatal|panic|critical)\b|^[EF]\d{4} `)
+
+// logRecord is a log line split into fields, lines that aren't JSON or logfmt only have a message
+type logRecord struct {
+	line string
+	// fields in the order of the line, JSON values that aren't strings are kept as compact JSON
+	keys   []string
+	fields map[string]string
+	// the object of a JSON line
+	object     string
+	time       string
+	level      string
+	message    string
+	structured bool
+}
+
+// parse a JSON or logfmt log line without its colors, a timestamp added by the Timestamps log option is used as time
+// when the line has no time field
+func parseLogRecord(line string) logRecord {
+	line = utils.RemoveANSIEscapeCodes(line)
+	record := logRecord{line: line, message: line}
+	prefix, text := splitLogTimestamp(line)
+	if keys, fields, ok := parseJSONFields(text); ok {
+		record.keys, record.fields, record.object = keys, fields, strings.TrimSpace(text)
+	} else if keys, fields, ok := parseLogfmtFields(text); ok {
+		record.keys, record.fields = keys, fields
+	} else {
+		record.time, record.message = prefix, text
+		return record
+	}
+
+	record.structured = true
+	record.time = getLogField(record.fields, logTimeKeys)
+	if record.time == "" {
+		record.time = prefix
+	}
+	record.level = getLogField(record.fields, logLevelKeys)
+	record.message = getLogField(record.fields, logMessageKeys)
+	return record
+}
+
+// get the time of a line from its timestamp added by the Timestamps log option, false when it has none,
+// and whether it is an error: the level of structured lines or error words in plain lines
+func getLogLineTime(line string) (time.Time, bool, bool) {
+	record := parseLogRecord(line)
+	prefix, _ := splitLogTimestamp(record.line)
+	if prefix == "" {
+		return time.Time{}, false, false
+	}
+	lineTime, _ := time.Parse(time.RFC3339Nano, prefix)
+	if record.structured {
+		return lineTime, getLogLevel(record.level) == "error", true
+	}
+	return lineTime, plainLogErrorPattern.MatchString(record.message), true
+}
+
+// split the RFC3339 timestamp the API adds to every line with the Timestamps log option
+func splitLogTimestamp(line string) (string, string) {
+	if i := strings.IndexByte(line, ' '); i > 0 {
+		if _, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+			return line[:i], line[i+1:]
+		}
+	}
+	return "", line
+}
+
+func getLogField(fields map[string]string, keys []string) string {
+	for _, key := range keys {
+		if value, ok := fields[key]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// parse the fields of a JSON object, false when text is anything else
+func parseJSONFields(text string) ([]string, map[string]string, bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "{") {
+		return nil, nil, false
+	}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, nil, false
+	}
+
+	var keys []string
+	fields := make(map[string]string)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, false
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, nil, false
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, nil, false
+		}
+		if _, seen := fields[key]; !seen {
+			keys = append(keys, key)
+		}
+		var text string
+		if err := json.Unmarshal(value, &text); err == nil {
+			fields[key] = text
+			continue
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, value); err != nil {
+			return nil, nil, false
+		}
+		fields[key] = compact.String()
+	}
+
+	// the object has to end the line
+	if token, err := decoder.Token(); err != nil || token != json.Delim('}') {
+		return nil, nil, false
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, nil, false
+	}
+	return keys, fields, true
+}
+
+// parse logfmt key=value pairs, values with spaces are quoted. Text is logfmt when it is made of
+// at least two pairs.
+func parseLogfmtFields(text string) ([]string, map[string]string, bool) {
+	var keys []string
+	fields := make(map[string]string)
+	for i := 0; i < len(text); {
+		if text[i] == ' ' || text[i] == '\t' {
+			i++
+			continue
+		}
+
+		keyStart := i
+		for i < len(text) && text[i] != '=' && text[i] != ' ' && text[i] != '"' {
+			i++
+		}
+		if i == keyStart || i == len(text) || text[i] != '=' {
+			return nil, nil, false
+		}
+		key := text[keyStart:i]
+		i++
+
+		var value string
+		if i < len(text) && text[i] == '"' {
+			valueStart := i
+			for i++; i < len(text) && text[i] != '"'; i++ {
+				if text[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(text) {
+				return nil, nil, false
+			}
+			i++
+			unquoted, err := strconv.Unquote(text[valueStart:i])
+			if err != nil {
+				return nil, nil, false
+			}
+			value = unquoted
+		} else {
+			valueStart := i
+			for i < len(text) && text[i] != ' ' && text[i] != '\t' {
+				i++
+			}
+			value = text[valueStart:i]
+		}
+
+		if _, seen := fields[key]; !seen {
+			keys = append(keys, key)
+		}
+		fields[key] = value
+	}
+	return keys, fields, len(keys) >= 2
+}
+
+// normalize a log level to error, warn, info or debug, numeric levels are bunyan/pino levels.
+// Unknown levels return an empty string.
+func getLogLevel(level string) string {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "error", "err", "e", "fatal", "panic", "dpanic", "critical", "crit", "alert", "emerg", "emergency", "50", "60":
+		return "error"
+	case "warn", "warning", "w", "40":
+		return "warn"
+	case "info", "information", "notice", "i", "30":
+		return "info"
+	case "debug", "trace", "verbose", "d", "20", "10":
+		return "debug"
+	}
+	return ""
+}
+
+func getLogLevelColor(level string) fyne.ThemeColorName {
+	switch getLogLevel(level) {
+	case "error":
+		return theme.ColorNameError
+	case "warn":
+		return theme.ColorNameWarning
+	case "debug":
+		return theme.ColorNameDisabled
+	}
+	return theme.ColorNameForeground
+}
+
+// get the record as indented JSON, logfmt fields become a JSON object of strings
+func getPrettyLogRecord(record logRecord) string {
+	if record.object != "" {
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, []byte(record.object), "", "  "); err == nil {
+			return pretty.String()
+		}
+	}
+	if !record.structured {
+		return record.line
+	}
+	var pretty strings.Builder
+	pretty.WriteString("{")
+	for i, key := range record.keys {
+		if i > 0 {
+			pretty.WriteString(",")
+		}
+		name, _ := json.Marshal(key)
+		value, _ := json.Marshal(record.fields[key])
+		pretty.WriteString("\n  " + string(name) + ": " + string(value))
+	}
+	pretty.WriteString("\n}")
+	return pretty.String()
+}
+
+// logFilterTerm matches a field value (key=value, key!=value) or text anywhere in the line
+type logFilterTerm struct {
+	key    string
+	value  string
+	negate bool
+}
+
+// parse a structured log filter, records have to match all space separated terms
+func parseLogFilter(text string) []logFilterTerm {
+	var terms []logFilterTerm
+	for _, field := range strings.Fields(text) {
+		if key, value, found := strings.Cut(field, "!="); found && key != "" {
+			terms = append(terms, logFilterTerm{key: key, value: value, negate: true})
+		} else if key, value, found := strings.Cut(field, "="); found && key != "" {
+			terms = append(terms, logFilterTerm{key: key, value: value})
+		} else {
+			terms = append(terms, logFilterTerm{value: field})
+		}
+	}
+	return terms
+}
+
+func (term logFilterTerm) matches(record logRecord) bool {
+	if term.key == "" {
+		return strings.Contains(strings.ToLower(record.line), strings.ToLower(term.value))
+	}
+
+	// level, time and message also match the field they were read from
+	value, ok := record.fields[term.key]
+	if !ok {
+		switch term.key {
+		case "level":
+			value = record.level
+		case "time":
+			value = record.time
+		case "msg", "message":
+			value = record.message
+		}
+	}
+
+	// level=error also matches ERROR, err and fatal
+	matched := strings.EqualFold(value, term.value)
+	if !matched && isLogLevelKey(term.key) && value != "" {
+		matched = getLogLevel(value) != "" && getLogLevel(value) == getLogLevel(term.value)
+	}
+	return matched != term.negate
+}
+
+func isLogLevelKey(key string) bool {
+	for _, levelKey := range logLevelKeys {
+		if key == levelKey {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesLogFilter reports whether the record matches all terms
+func matchesLogFilter(record logRecord, terms []logFilterTerm) bool {
+	for _, term := range terms {
+		if !term.matches(record) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,443 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/michaeljsaenz/kview/internal/k8s"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// how often followed lines are added to the log view
+const logFlushInterval = 250 * time.Millisecond
+
+// tail choices of the log query, "All" shows the whole log
+var logTailOptions = []string{"100", "1000", "10000", "All"}
+
+// LogTab is the content of a container log tab: the log text with query, follow and pause controls.
+// Following streams new lines until the tab or pod changes, call Close when the tab is left.
+type LogTab struct {
+	Content fyne.CanvasObject
+
+	ctx           context.Context
+	loader        *Loader
+	clientset     kubernetes.Clientset
+	getPod        func(ctx context.Context) (*corev1.Pod, error)
+	podNamespace  string
+	podName       string
+	containerName string
+	showError     func(action string, err error, retry func())
+
+	view            *LogView
+	search          *LogSearch
+	histogram       *LogHistogram
+	structured      *StructuredLogView
+	body            *fyne.Container
+	structuredCheck *widget.Check
+	colorsCheck     *widget.Check
+	previousCheck   *widget.Check
+	sinceEntry      *widget.Entry
+	tailSelect      *widget.Select
+	timestampsCheck *widget.Check
+	followCheck     *widget.Check
+	pauseButton     *widget.Button
+	statusLabel     *widget.Label
+	hint            *fyne.Container
+	hintLabel       *widget.Label
+
+	mutex   sync.Mutex
+	options k8s.LogOptions
+	pending []string
+	// oldest pending lines dropped while paused, pending keeps at most logViewCapacity lines
+	dropped int
+	paused  bool
+	cancel  context.CancelFunc
+}
+
+// NewLogTab creates the log view of a container, ctx is the pod selection and getPod returns
+// the selected pod for the restart hint
+func NewLogTab(ctx context.Context, loader *Loader, clientset kubernetes.Clientset, getPod func(ctx context.Context) (*corev1.Pod, error),
+	podNamespace string, podName string, containerName string, prefs fyne.Preferences, showError func(action string, err error, retry func())) *LogTab {
+	t := &LogTab{ctx: ctx, loader: loader, clientset: clientset, getPod: getPod, podNamespace: podNamespace, podName: podName,
+		containerName: containerName, showError: showError, options: k8s.DefaultLogOptions(), cancel: func() {}}
+
+	t.view = NewLogView()
+	t.search = NewLogSearch(t.view, prefs)
+	// log volume over time, zooming narrows the view to the lines of a time window
+	t.histogram = NewLogHistogram()
+	t.histogram.OnZoom = func(first int, last int) {
+		t.view.SetLineRange(first, last)
+		t.search.UpdateCount()
+	}
+	t.structured = NewStructuredLogView()
+	t.body = container.NewMax(t.view)
+	// offered once the log turns out to be JSON or logfmt
+	t.structuredCheck = widget.NewCheck("Structured", t.setStructured)
+	t.structuredCheck.Disable()
+	// ANSI colors of the log lines, plain text when unchecked
+	t.colorsCheck = widget.NewCheck("Colors", t.view.SetColors)
+	t.colorsCheck.Checked = true
+
+	// log query, changes reload the log or restart following
+	t.previousCheck = widget.NewCheck("Previous", func(bool) { t.reload() })
+	t.sinceEntry = widget.NewEntry()
+	t.sinceEntry.SetPlaceHolder("Since: 15m, 2d or 2006-01-02 15:04")
+	t.sinceEntry.OnSubmitted = func(string) { t.reload() }
+	t.tailSelect = widget.NewSelect(logTailOptions, func(string) { t.reload() })
+	t.tailSelect.Selected = strconv.FormatInt(t.options.TailLines, 10)
+	t.timestampsCheck = widget.NewCheck("Timestamps", func(bool) { t.reload() })
+	t.histogram.OnTimestamps = func() { t.timestampsCheck.SetChecked(true) }
+
+	// restart hint, previous logs exist once the container restarted
+	t.hintLabel = widget.NewLabel("")
+	t.hintLabel.Wrapping = fyne.TextWrapWord
+	t.hint = container.NewBorder(nil, nil, widget.NewIcon(theme.WarningIcon()), nil, t.hintLabel)
+	t.hint.Hide()
+
+	t.statusLabel = widget.NewLabel("")
+	t.pauseButton = widget.NewButtonWithIcon("Pause", theme.MediaPauseIcon(), t.togglePause)
+	t.pauseButton.Hide()
+	t.followCheck = widget.NewCheck("Follow", func(follow bool) {
+		if follow {
+			t.follow()
+		} else {
+			t.stopFollow()
+		}
+	})
+
+	// jump to either end, copy the selected lines (all lines without a selection), save the log to a file
+	topButton := widget.NewButtonWithIcon("", theme.MoveUpIcon(), t.view.ScrollToTop)
+	bottomButton := widget.NewButtonWithIcon("", theme.MoveDownIcon(), t.view.ScrollToBottom)
+	copyButton := widget.NewButtonWithIcon("", theme.ContentCopyIcon(), t.view.CopySelection)
+	saveButton := widget.NewButtonWithIcon("", theme.DocumentSaveIcon(), t.save)
+
+	query := container.NewHBox(t.previousCheck, widget.NewLabel("Tail"), t.tailSelect, t.timestampsCheck)
+	controls := container.NewHBox(t.colorsCheck, t.structuredCheck, t.followCheck, t.pauseButton, t.statusLabel, topButton, bottomButton, copyButton, saveButton)
+	toolbar := container.NewVBox(container.NewBorder(nil, nil, query, controls, t.sinceEntry), t.search.Content, t.hint, t.histogram.Content)
+	t.Content = container.NewBorder(toolbar, nil, nil, nil, t.body)
+	return t
+}
+
+// Load shows the part of the container log selected by the log query
+func (t *LogTab) Load() {
+	t.statusLabel.SetText("Loading...")
+	t.loadHint()
+	options := t.getOptions()
+	t.loader.Run(t.ctx, func(ctx context.Context) func() {
+		containerLogStream, err := k8s.GetPodLogs(ctx, t.clientset, t.podNamespace, t.podName, t.containerName, options)
+		return func() {
+			if err != nil {
+				t.showError("Get logs for container "+t.containerName, err, t.Load)
+			}
+			// following replaced the tail in the meantime
+			if t.followCheck.Checked {
+				return
+			}
+			t.view.SetText(containerLogStream)
+			t.statusLabel.SetText(strconv.Itoa(t.view.LineCount()) + " lines")
+			t.search.UpdateCount()
+			lines := t.view.Lines()
+			t.histogram.SetLines(lines)
+			if isStructuredLog(lines) {
+				t.structuredCheck.Enable()
+			}
+			if t.structuredCheck.Checked {
+				t.structured.SetLines(lines)
+			}
+		}
+	})
+}
+
+// Close stops following the log
+func (t *LogTab) Close() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.cancel()
+}
+
+// save the shown log lines as plain text
+func (t *LogTab) save() {
+	win := getWindowForObject(t.Content)
+	if win == nil {
+		return
+	}
+	text := t.view.Text()
+	if text != "" {
+		text += "\n"
+	}
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			t.showError("Save log of container "+t.containerName, err, nil)
+			return
+		}
+		// canceled
+		if writer == nil {
+			return
+		}
+		_, err = io.WriteString(writer, text)
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			t.showError("Save log of container "+t.containerName, err, t.save)
+		}
+	}, win)
+	saveDialog.SetFileName(getLogFileName(t.podName, t.containerName, t.getOptions().Previous, time.Now()))
+	saveDialog.Show()
+}
+
+// file name of a saved container log, e.g. web-app-20240102-030405.log
+func getLogFileName(podName string, containerName string, previous bool, now time.Time) string {
+	name := podName + "-" + containerName
+	if previous {
+		name += "-previous"
+	}
+	return name + "-" + now.Format("20060102-150405") + ".log"
+}
+
+// switch between the log lines and the structured view of JSON and logfmt lines
+func (t *LogTab) setStructured(structured bool) {
+	if structured {
+		t.structured.SetLines(t.view.Lines())
+		t.search.Content.Hide()
+		t.histogram.Content.Hide()
+		t.body.Objects = []fyne.CanvasObject{t.structured.Content}
+	} else {
+		t.search.Content.Show()
+		t.histogram.Content.Show()
+		t.body.Objects = []fyne.CanvasObject{t.view}
+	}
+	t.body.Refresh()
+}
+
+func (t *LogTab) getOptions() k8s.LogOptions {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.options
+}
+
+// apply the log query controls and load the log again
+func (t *LogTab) reload() {
+	since, sinceTime, err := parseLogSince(t.sinceEntry.Text)
+	if err != nil {
+		t.statusLabel.SetText(err.Error())
+		return
+	}
+	var tailLines int64
+	if t.tailSelect.Selected != "All" {
+		tailLines, _ = strconv.ParseInt(t.tailSelect.Selected, 10, 64)
+	}
+
+	t.mutex.Lock()
+	t.options = k8s.LogOptions{Previous: t.previousCheck.Checked, Since: since, SinceTime: sinceTime,
+		TailLines: tailLines, Timestamps: t.timestampsCheck.Checked}
+	t.mutex.Unlock()
+
+	t.statusLabel.SetText("")
+	if t.followCheck.Checked {
+		t.follow()
+	} else {
+		t.Load()
+	}
+}
+
+// show how often the container restarted and when it last terminated
+func (t *LogTab) loadHint() {
+	previous := t.previousCheck.Checked
+	t.loader.Run(t.ctx, func(ctx context.Context) func() {
+		pod, err := t.getPod(ctx)
+		return func() {
+			if err != nil {
+				return
+			}
+			status, ok := k8s.GetContainerStatus(pod, t.containerName)
+			if !ok || previous || (status.RestartCount == 0 && status.LastTerminationState.Terminated == nil) {
+				t.hint.Hide()
+				return
+			}
+			t.hintLabel.SetText(getRestartHint(status))
+			t.hint.Show()
+		}
+	})
+}
+
+func getRestartHint(status corev1.ContainerStatus) string {
+	hint := "Container restarted " + strconv.Itoa(int(status.RestartCount)) + " times"
+	if terminated := status.LastTerminationState.Terminated; terminated != nil {
+		reason := terminated.Reason
+		if reason == "" {
+			reason = "terminated"
+		}
+		hint += ", last " + reason + " (exit code " + strconv.Itoa(int(terminated.ExitCode)) + ")"
+		if !terminated.FinishedAt.IsZero() {
+			hint += " at " + terminated.FinishedAt.Local().Format("2006-01-02 15:04:05")
+		}
+	}
+	return hint + ". Check Previous for the logs before the restart."
+}
+
+// parse the since field, a duration (15m, 2d) or a local time (2006-01-02 15:04), empty for no limit
+func parseLogSince(text string) (time.Duration, time.Time, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, time.Time{}, nil
+	}
+	if days, found := strings.CutSuffix(text, "d"); found {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, time.Time{}, nil
+		}
+	}
+	if since, err := time.ParseDuration(text); err == nil && since > 0 {
+		return since, time.Time{}, nil
+	}
+	if sinceTime, err := time.Parse(time.RFC3339, text); err == nil {
+		return 0, sinceTime, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if sinceTime, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return 0, sinceTime, nil
+		}
+	}
+	return 0, time.Time{}, fmt.Errorf("invalid since %q", text)
+}
+
+func (t *LogTab) follow() {
+	ctx, cancel := context.WithCancel(t.ctx)
+	t.mutex.Lock()
+	t.cancel()
+	t.cancel = cancel
+	t.pending, t.dropped, t.paused = nil, 0, false
+	t.mutex.Unlock()
+
+	t.view.SetText("")
+	t.histogram.SetLines(nil)
+	if t.structuredCheck.Checked {
+		t.structured.SetLines(nil)
+	}
+	t.pauseButton.SetText("Pause")
+	t.pauseButton.SetIcon(theme.MediaPauseIcon())
+	t.pauseButton.Show()
+	t.statusLabel.SetText("Following")
+	t.loadHint()
+	options := t.getOptions()
+
+	// lines are collected by the stream and shown in batches
+	go func() {
+		ticker := time.NewTicker(logFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.flush()
+			}
+		}
+	}()
+
+	go func() {
+		err := k8s.StreamPodLogs(ctx, &t.clientset, t.podNamespace, t.podName, t.containerName, options, t.addLine)
+		if ctx.Err() != nil {
+			return
+		}
+		cancel()
+		t.flush()
+		if err != nil {
+			t.statusLabel.SetText("Stream failed")
+			t.showError("Follow logs for container "+t.containerName, err, func() {
+				t.followCheck.SetChecked(false)
+				t.followCheck.SetChecked(true)
+			})
+			return
+		}
+		t.statusLabel.SetText("Stream ended")
+	}()
+}
+
+// collect a streamed line, a long pause keeps the newest lines like the view does
+func (t *LogTab) addLine(line string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.pending = append(t.pending, line)
+	if len(t.pending) > logViewCapacity {
+		t.pending = t.pending[1:]
+		t.dropped++
+	}
+}
+
+func (t *LogTab) stopFollow() {
+	t.mutex.Lock()
+	t.cancel()
+	t.paused = false
+	t.mutex.Unlock()
+	t.flush()
+	t.pauseButton.Hide()
+	t.statusLabel.SetText("")
+}
+
+// pause keeps collecting lines without showing them, resume shows them
+func (t *LogTab) togglePause() {
+	t.mutex.Lock()
+	t.paused = !t.paused
+	paused := t.paused
+	t.mutex.Unlock()
+
+	if paused {
+		t.pauseButton.SetText("Resume")
+		t.pauseButton.SetIcon(theme.MediaPlayIcon())
+		t.statusLabel.SetText("Paused")
+		return
+	}
+	t.pauseButton.SetText("Pause")
+	t.pauseButton.SetIcon(theme.MediaPauseIcon())
+	t.statusLabel.SetText("Following")
+	t.flush()
+}
+
+// add the collected lines to the view, keeping it scrolled to the bottom unless scrolled up
+func (t *LogTab) flush() {
+	t.mutex.Lock()
+	if len(t.pending) == 0 {
+		t.mutex.Unlock()
+		return
+	}
+	if t.paused {
+		status := "Paused, " + strconv.Itoa(len(t.pending)) + " new lines"
+		if t.dropped > 0 {
+			status += ", " + strconv.Itoa(t.dropped) + " older lines dropped"
+		}
+		t.mutex.Unlock()
+		t.statusLabel.SetText(status)
+		return
+	}
+	pending, dropped := t.pending, t.dropped
+	t.pending, t.dropped = nil, 0
+	t.mutex.Unlock()
+
+	atBottom := t.view.AtBottom()
+	t.view.AppendLines(pending)
+	t.histogram.AppendLines(pending)
+	t.search.UpdateCount()
+	if t.structuredCheck.Checked {
+		t.structured.AppendLines(pending)
+	} else if t.structuredCheck.Disabled() && isStructuredLog(pending) {
+		t.structuredCheck.Enable()
+	}
+	if atBottom {
+		t.view.ScrollToBottom()
+	}
+	if dropped > 0 {
+		t.statusLabel.SetText("Following, " + strconv.Itoa(dropped) + " lines dropped while paused")
+	}
+}
@@ -0,0 +1,260 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// preference key of the saved log search presets
+const logPresetsKey = "log.presets"
+
+// context line choices of the log filter, like grep -C
+var logContextOptions = []string{"0", "1", "2", "3", "5", "10"}
+
+// logPreset is a saved log search
+type logPreset struct {
+	Name      string `json:"name"`
+	Pattern   string `json:"pattern"`
+	Regex     bool   `json:"regex"`
+	MatchCase bool   `json:"matchCase"`
+	Filter    bool   `json:"filter"`
+	Context   int    `json:"context"`
+}
+
+// presets offered until presets are saved
+var defaultLogPresets = []logPreset{
+	{Name: "Errors only", Pattern: `\b(error|err|fatal|panic|exception)\b`, Regex: true, Filter: true},
+	{Name: "Warnings and errors", Pattern: `\b(warn|warning|error|err|fatal|panic|exception)\b`, Regex: true, Filter: true},
+}
+
+// LogSearch is the search bar of a log view: plain text or regex search with highlighted matches,
+// next/previous navigation, a filter to the matching lines and saved presets
+type LogSearch struct {
+	Content fyne.CanvasObject
+
+	view  *LogView
+	prefs fyne.Preferences
+
+	entry         *widget.Entry
+	regexCheck    *widget.Check
+	caseCheck     *widget.Check
+	filterCheck   *widget.Check
+	contextSelect *widget.Select
+	countLabel    *widget.Label
+	presetSelect  *widget.Select
+	deleteButton  *widget.Button
+	presets       []logPreset
+	// position of the current match, 0 before navigating
+	position int
+	// set while a preset changes the controls, the search is applied once at the end
+	applyingPreset bool
+}
+
+func NewLogSearch(view *LogView, prefs fyne.Preferences) *LogSearch {
+	s := &LogSearch{view: view, prefs: prefs, presets: loadLogPresets(prefs)}
+
+	s.entry = widget.NewEntry()
+	s.entry.SetPlaceHolder("Search logs")
+	s.entry.OnChanged = func(string) { s.apply() }
+	s.entry.OnSubmitted = func(string) { s.next(true) }
+	s.regexCheck = widget.NewCheck("Regex", func(bool) { s.apply() })
+	s.caseCheck = widget.NewCheck("Match case", func(bool) { s.apply() })
+	s.contextSelect = widget.NewSelect(logContextOptions, func(string) { s.apply() })
+	s.contextSelect.Selected = logContextOptions[0]
+	s.contextSelect.Disable()
+	s.filterCheck = widget.NewCheck("Only matching", func(filter bool) {
+		if filter {
+			s.contextSelect.Enable()
+		} else {
+			s.contextSelect.Disable()
+		}
+		s.apply()
+	})
+	s.countLabel = widget.NewLabel("")
+
+	previousButton := widget.NewButtonWithIcon("", theme.NavigateBackIcon(), func() { s.next(false) })
+	nextButton := widget.NewButtonWithIcon("", theme.NavigateNextIcon(), func() { s.next(true) })
+
+	s.presetSelect = widget.NewSelect(getLogPresetNames(s.presets), s.usePreset)
+	s.presetSelect.PlaceHolder = "Presets"
+	saveButton := widget.NewButtonWithIcon("", theme.DocumentSaveIcon(), s.showSavePreset)
+	s.deleteButton = widget.NewButtonWithIcon("", theme.DeleteIcon(), s.deletePreset)
+	s.deleteButton.Disable()
+
+	search := container.NewBorder(nil, nil, widget.NewIcon(theme.SearchIcon()),
+		container.NewHBox(s.countLabel, previousButton, nextButton), s.entry)
+	options := container.NewBorder(nil, nil,
+		container.NewHBox(s.regexCheck, s.caseCheck, s.filterCheck, widget.NewLabel("Context"), s.contextSelect),
+		container.NewHBox(s.presetSelect, saveButton, s.deleteButton))
+	s.Content = container.NewVBox(search, options)
+	return s
+}
+
+// UpdateCount shows the number of matching lines again, e.g. after lines were added
+func (s *LogSearch) UpdateCount() {
+	if s.entry.Text == "" {
+		s.countLabel.SetText("")
+		return
+	}
+	switch count := s.view.MatchCount(); {
+	case count == 0:
+		s.countLabel.SetText("No matches")
+	case s.position > 0:
+		s.countLabel.SetText(strconv.Itoa(s.position) + " of " + strconv.Itoa(count))
+	default:
+		s.countLabel.SetText(strconv.Itoa(count) + " matches")
+	}
+}
+
+// search the log view with the search controls
+func (s *LogSearch) apply() {
+	if s.applyingPreset {
+		return
+	}
+	s.position = 0
+	search, err := compileLogSearch(s.entry.Text, s.regexCheck.Checked, s.caseCheck.Checked)
+	if err != nil {
+		s.view.SetSearch(nil, false, 0)
+		s.countLabel.SetText("Invalid regex")
+		return
+	}
+	filterContext, _ := strconv.Atoi(s.contextSelect.Selected)
+	s.view.SetSearch(search, s.filterCheck.Checked, filterContext)
+	s.UpdateCount()
+}
+
+func (s *LogSearch) next(forward bool) {
+	s.position, _ = s.view.NextMatch(forward)
+	s.UpdateCount()
+}
+
+// compile the search, plain text matches literally and ignores case unless matchCase is set.
+// An empty pattern returns nil.
+func compileLogSearch(pattern string, regex bool, matchCase bool) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if !regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !matchCase {
+		pattern = "(?i)" + pattern
+	}
+	search, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search %q: %w", pattern, err)
+	}
+	return search, nil
+}
+
+func (s *LogSearch) usePreset(name string) {
+	for _, preset := range s.presets {
+		if preset.Name != name {
+			continue
+		}
+		s.applyingPreset = true
+		s.regexCheck.SetChecked(preset.Regex)
+		s.caseCheck.SetChecked(preset.MatchCase)
+		s.contextSelect.SetSelected(strconv.Itoa(preset.Context))
+		s.filterCheck.SetChecked(preset.Filter)
+		s.entry.SetText(preset.Pattern)
+		s.applyingPreset = false
+		s.deleteButton.Enable()
+		s.apply()
+		return
+	}
+	s.deleteButton.Disable()
+}
+
+// save the current search under a name, a preset with the same name is replaced
+func (s *LogSearch) showSavePreset() {
+	win := getWindowForObject(s.Content)
+	if win == nil || s.entry.Text == "" {
+		return
+	}
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(s.presetSelect.Selected)
+	items := []*widget.FormItem{widget.NewFormItem("Name", nameEntry)}
+	dialog.ShowForm("Save Search", "Save", "Cancel", items, func(save bool) {
+		if !save || nameEntry.Text == "" {
+			return
+		}
+		filterContext, _ := strconv.Atoi(s.contextSelect.Selected)
+		preset := logPreset{Name: nameEntry.Text, Pattern: s.entry.Text, Regex: s.regexCheck.Checked,
+			MatchCase: s.caseCheck.Checked, Filter: s.filterCheck.Checked, Context: filterContext}
+		s.presets = setLogPreset(s.presets, preset)
+		s.savePresets(preset.Name)
+	}, win)
+}
+
+func (s *LogSearch) deletePreset() {
+	name := s.presetSelect.Selected
+	presets := make([]logPreset, 0, len(s.presets))
+	for _, preset := range s.presets {
+		if preset.Name != name {
+			presets = append(presets, preset)
+		}
+	}
+	s.presets = presets
+	s.savePresets("")
+}
+
+func (s *LogSearch) savePresets(selected string) {
+	saveLogPresets(s.prefs, s.presets)
+	s.presetSelect.Options = getLogPresetNames(s.presets)
+	// the controls already show the preset
+	s.presetSelect.Selected = selected
+	s.presetSelect.Refresh()
+	if selected == "" {
+		s.deleteButton.Disable()
+	} else {
+		s.deleteButton.Enable()
+	}
+}
+
+// add a preset or replace the preset with the same name
+func setLogPreset(presets []logPreset, preset logPreset) []logPreset {
+	for i := range presets {
+		if presets[i].Name == preset.Name {
+			presets[i] = preset
+			return presets
+		}
+	}
+	return append(presets, preset)
+}
+
+func getLogPresetNames(presets []logPreset) []string {
+	names := make([]string, len(presets))
+	for i, preset := range presets {
+		names[i] = preset.Name
+	}
+	return names
+}
+
+// get the saved presets, the default presets until presets are saved
+func loadLogPresets(prefs fyne.Preferences) []logPreset {
+	var presets []logPreset
+	if err := json.Unmarshal([]byte(prefs.String(logPresetsKey)), &presets); err != nil {
+		return append([]logPreset(nil), defaultLogPresets...)
+	}
+	return presets
+}
+
+func saveLogPresets(prefs fyne.Preferences, presets []logPreset) {
+	if presets == nil {
+		presets = []logPreset{}
+	}
+	data, err := json.Marshal(presets)
+	if err != nil {
+		return
+	}
+	prefs.SetString(logPresetsKey, string(data))
+}
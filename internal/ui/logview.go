@@ -0,0 +1,886 @@
+package ui
+
+import (
+	"image/color"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/michaeljsaenz/kview/internal/utils"
+)
+
+const (
+	// lines kept by a log view, older lines are dropped
+	logViewCapacity = 300000
+	// longest part of a line that is drawn, the full line is kept for copying
+	maxLogLineDisplay = 4096
+)
+
+// LogView shows log lines from a bounded ring buffer. Only the visible lines are drawn, so it
+// stays fast with hundreds of thousands of lines. Lines are numbered. A range of lines can be
+// selected with click and shift+click (or drag) and copied. A search highlights its matches and
+// can filter the view to the matching lines. A line range narrows the view further. ANSI escape
+// sequences color the text, search and copy use the text without them.
+type LogView struct {
+	widget.BaseWidget
+
+	scroll  *container.Scroll
+	content *logContent
+
+	mutex  sync.RWMutex
+	buffer *logBuffer
+	// selected range as line numbers (starting at 1), 0 when nothing is selected
+	selectionAnchor int
+	selectionEnd    int
+
+	// escape sequences color the text, or are removed when colors is false
+	colors      bool
+	lineNumbers bool
+
+	// search highlights matching lines, filter shows only them and the context lines around them
+	search        *regexp.Regexp
+	filter        bool
+	filterContext int
+	// line numbers of the matching lines and, while filtering, of the shown lines
+	matches      []int
+	rows         []int
+	lastMatch    int
+	currentMatch int
+	// only lines from rangeFirst to rangeLast are shown, 0 when not limited
+	rangeFirst int
+	rangeLast  int
+}
+
+func NewLogView() *LogView {
+	v := &LogView{buffer: newLogBuffer(logViewCapacity), colors: true, lineNumbers: true}
+	v.content = &logContent{view: v}
+	v.content.ExtendBaseWidget(v.content)
+	v.scroll = container.NewScroll(v.content)
+	v.scroll.OnScrolled = func(fyne.Position) {
+		v.content.Refresh()
+	}
+	v.ExtendBaseWidget(v)
+	return v
+}
+
+func (v *LogView) CreateRenderer() fyne.WidgetRenderer {
+	return &logViewRenderer{view: v}
+}
+
+// SetText replaces the lines with the lines of text
+func (v *LogView) SetText(text string) {
+	var lines []string
+	if text != "" {
+		lines = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	}
+	v.SetLines(lines)
+}
+
+// SetLines replaces the lines, line numbers start at 1 again
+func (v *LogView) SetLines(lines []string) {
+	v.mutex.Lock()
+	v.buffer.reset()
+	v.buffer.append(lines)
+	v.selectionAnchor, v.selectionEnd = 0, 0
+	v.currentMatch = 0
+	v.rangeFirst, v.rangeLast = 0, 0
+	v.resetSearch()
+	v.mutex.Unlock()
+	v.refreshContent()
+	v.scroll.ScrollToTop()
+}
+
+// SetLineRange shows only the lines from line number first to last, 0 and 0 show all lines
+func (v *LogView) SetLineRange(first int, last int) {
+	v.mutex.Lock()
+	v.rangeFirst, v.rangeLast = first, last
+	v.currentMatch = 0
+	v.mutex.Unlock()
+	v.refreshContent()
+	v.scroll.ScrollToTop()
+}
+
+// AppendLines adds lines at the end, dropping the oldest lines when the buffer is full
+func (v *LogView) AppendLines(lines []string) {
+	v.mutex.Lock()
+	v.buffer.append(lines)
+	v.trimSearch()
+	// only the last lines are new, all of them when the buffer was overwritten
+	from := v.buffer.count - len(lines)
+	if from < 0 {
+		from = 0
+	}
+	v.indexLines(from)
+	v.mutex.Unlock()
+	v.refreshContent()
+}
+
+// Lines returns a copy of the lines in the view
+func (v *LogView) Lines() []string {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return getPlainLines(v.buffer.slice(0, v.buffer.count))
+}
+
+// LineCount returns the number of lines in the view
+func (v *LogView) LineCount() int {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return v.buffer.count
+}
+
+// Text returns the lines in the view joined by newlines
+func (v *LogView) Text() string {
+	return strings.Join(v.Lines(), "\n")
+}
+
+// SelectedText returns the selected lines, all shown lines when nothing is selected.
+// While filtering only the shown lines are included.
+func (v *LogView) SelectedText() string {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	first, last := v.shownRange()
+	if v.selectionAnchor != 0 {
+		selectionFirst, selectionLast := v.selection()
+		if selectionFirst > first {
+			first = selectionFirst
+		}
+		if selectionLast < last {
+			last = selectionLast
+		}
+	}
+
+	var lines []string
+	if v.filter {
+		for _, lineNumber := range v.rows[sort.SearchInts(v.rows, first):] {
+			if lineNumber > last {
+				break
+			}
+			lines = append(lines, utils.RemoveANSIEscapeCodes(v.lineText(lineNumber)))
+		}
+	} else if first <= last {
+		lines = getPlainLines(v.buffer.slice(first-v.buffer.dropped-1, last-v.buffer.dropped))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CopySelection copies the selected lines to the clipboard of the window showing the view
+func (v *LogView) CopySelection() {
+	if win := getWindowForObject(v); win != nil {
+		win.Clipboard().SetContent(v.SelectedText())
+	}
+}
+
+// get the window showing a widget, nil when it isn't shown
+func getWindowForObject(o fyne.CanvasObject) fyne.Window {
+	app := fyne.CurrentApp()
+	if app == nil {
+		return nil
+	}
+	c := app.Driver().CanvasForObject(o)
+	if c == nil {
+		return nil
+	}
+	for _, window := range app.Driver().AllWindows() {
+		if window.Canvas() == c {
+			return window
+		}
+	}
+	return nil
+}
+
+// first and last selected line number, call with the mutex held
+func (v *LogView) selection() (int, int) {
+	if v.selectionAnchor <= v.selectionEnd {
+		return v.selectionAnchor, v.selectionEnd
+	}
+	return v.selectionEnd, v.selectionAnchor
+}
+
+// ClearSelection unselects all lines
+func (v *LogView) ClearSelection() {
+	v.mutex.Lock()
+	v.selectionAnchor, v.selectionEnd = 0, 0
+	v.mutex.Unlock()
+	v.content.Refresh()
+}
+
+func (v *LogView) ScrollToTop() {
+	v.scroll.ScrollToTop()
+}
+
+func (v *LogView) ScrollToBottom() {
+	v.scroll.Refresh()
+	v.scroll.ScrollToBottom()
+}
+
+// AtBottom reports whether the last line is visible, new lines keep it scrolled to the bottom
+func (v *LogView) AtBottom() bool {
+	_, lineHeight := getLogCellSize()
+	return v.scroll.Offset.Y+v.scroll.Size().Height >= v.content.MinSize().Height-lineHeight
+}
+
+// SetColors shows the colors and styles of ANSI escape sequences, false shows plain text
+func (v *LogView) SetColors(colors bool) {
+	v.mutex.Lock()
+	v.colors = colors
+	v.mutex.Unlock()
+	v.content.Refresh()
+}
+
+// SetLineNumbers shows or hides the line numbers
+func (v *LogView) SetLineNumbers(lineNumbers bool) {
+	v.mutex.Lock()
+	v.lineNumbers = lineNumbers
+	v.mutex.Unlock()
+	v.refreshContent()
+}
+
+// SetSearch highlights the matches of search, with filter only the matching lines and filterContext
+// lines before and after them are shown. A nil search clears the search.
+func (v *LogView) SetSearch(search *regexp.Regexp, filter bool, filterContext int) {
+	v.mutex.Lock()
+	v.search, v.filter, v.filterContext = search, filter && search != nil, filterContext
+	v.currentMatch = 0
+	v.resetSearch()
+	v.mutex.Unlock()
+	v.refreshContent()
+	v.scroll.ScrollToTop()
+}
+
+// MatchCount returns the number of shown lines matching the search
+func (v *LogView) MatchCount() int {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return len(v.shownMatches())
+}
+
+// NextMatch scrolls to the next (or previous) matching line and returns its position and the number
+// of matching lines, moving past the last match wraps around
+func (v *LogView) NextMatch(forward bool) (int, int) {
+	v.mutex.Lock()
+	matches := v.shownMatches()
+	total := len(matches)
+	if total == 0 {
+		v.currentMatch = 0
+		v.mutex.Unlock()
+		return 0, 0
+	}
+	i := sort.SearchInts(matches, v.currentMatch)
+	found := i < total && matches[i] == v.currentMatch
+	if forward && found {
+		i++
+	} else if !forward {
+		i--
+	}
+	i = (i + total) % total
+	v.currentMatch = matches[i]
+	row := v.rowOf(v.currentMatch)
+	v.mutex.Unlock()
+
+	v.scrollToRow(row)
+	return i + 1, total
+}
+
+// scroll the row to the middle of the view
+func (v *LogView) scrollToRow(row int) {
+	_, lineHeight := getLogCellSize()
+	v.scroll.Offset.Y = fyne.Max(0, float32(row)*lineHeight-(v.scroll.Size().Height-lineHeight)/2)
+	v.scroll.Refresh()
+	v.content.Refresh()
+}
+
+// find the matches again, call with the mutex held
+func (v *LogView) resetSearch() {
+	v.matches, v.rows, v.lastMatch = nil, nil, 0
+	v.indexLines(0)
+}
+
+// find the matches from buffer index from on, call with the mutex held
+func (v *LogView) indexLines(from int) {
+	if v.search == nil {
+		return
+	}
+	for index := from; index < v.buffer.count; index++ {
+		lineNumber := v.buffer.dropped + index + 1
+		matched := v.search.MatchString(utils.RemoveANSIEscapeCodes(v.buffer.line(index)))
+		if matched {
+			v.matches = append(v.matches, lineNumber)
+		}
+		if !v.filter {
+			continue
+		}
+		if matched {
+			// context lines before the match that aren't shown yet
+			first := lineNumber - v.filterContext
+			if len(v.rows) > 0 && first <= v.rows[len(v.rows)-1] {
+				first = v.rows[len(v.rows)-1] + 1
+			}
+			if first <= v.buffer.dropped {
+				first = v.buffer.dropped + 1
+			}
+			for n := first; n <= lineNumber; n++ {
+				v.rows = append(v.rows, n)
+			}
+			v.lastMatch = lineNumber
+		} else if v.lastMatch > 0 && lineNumber-v.lastMatch <= v.filterContext {
+			v.rows = append(v.rows, lineNumber)
+		}
+	}
+}
+
+// forget the matches of dropped lines, call with the mutex held
+func (v *LogView) trimSearch() {
+	first := v.buffer.dropped + 1
+	v.matches = v.matches[sort.SearchInts(v.matches, first):]
+	v.rows = v.rows[sort.SearchInts(v.rows, first):]
+}
+
+// first and last line number that can be shown, the line range within the buffer. Dropped lines
+// can't be shown anymore. Call with the mutex held.
+func (v *LogView) shownRange() (int, int) {
+	first, last := v.buffer.dropped+1, v.buffer.dropped+v.buffer.count
+	if v.rangeFirst > first {
+		first = v.rangeFirst
+	}
+	if v.rangeLast > 0 && v.rangeLast < last {
+		last = v.rangeLast
+	}
+	return first, last
+}
+
+// the matches within the line range, call with the mutex held
+func (v *LogView) shownMatches() []int {
+	first, last := v.shownRange()
+	return v.matches[sort.SearchInts(v.matches, first):sort.SearchInts(v.matches, last+1)]
+}
+
+// index of the first filtered row within the line range, call with the mutex held
+func (v *LogView) firstRow() int {
+	first, _ := v.shownRange()
+	return sort.SearchInts(v.rows, first)
+}
+
+// number of drawn rows, call with the mutex held
+func (v *LogView) rowCount() int {
+	first, last := v.shownRange()
+	if v.filter {
+		return sort.SearchInts(v.rows, last+1) - v.firstRow()
+	}
+	if last < first {
+		return 0
+	}
+	return last - first + 1
+}
+
+// line number drawn in a row, call with the mutex held
+func (v *LogView) rowLine(row int) int {
+	if v.filter {
+		return v.rows[v.firstRow()+row]
+	}
+	first, _ := v.shownRange()
+	return first + row
+}
+
+// row drawing a line number, call with the mutex held
+func (v *LogView) rowOf(lineNumber int) int {
+	if v.filter {
+		return sort.SearchInts(v.rows, lineNumber) - v.firstRow()
+	}
+	first, _ := v.shownRange()
+	return lineNumber - first
+}
+
+// text of a line number in the buffer, call with the mutex held
+func (v *LogView) lineText(lineNumber int) string {
+	return v.buffer.line(lineNumber - v.buffer.dropped - 1)
+}
+
+func (v *LogView) refreshContent() {
+	v.content.Refresh()
+	v.scroll.Refresh()
+}
+
+// select the line at y in content coordinates, extend keeps the anchor of the selection
+func (v *LogView) selectLineAt(y float32, extend bool) {
+	_, lineHeight := getLogCellSize()
+	v.mutex.Lock()
+	rows := v.rowCount()
+	if rows == 0 {
+		v.mutex.Unlock()
+		return
+	}
+	lineNumber := v.rowLine(int(math.Max(0, math.Min(float64(y/lineHeight), float64(rows-1)))))
+	if !extend || v.selectionAnchor == 0 {
+		v.selectionAnchor = lineNumber
+	}
+	v.selectionEnd = lineNumber
+	v.mutex.Unlock()
+	v.content.Refresh()
+}
+
+type logViewRenderer struct {
+	view *LogView
+}
+
+func (r *logViewRenderer) Layout(size fyne.Size) {
+	r.view.scroll.Resize(size)
+	// a taller view shows more lines
+	r.view.content.Refresh()
+}
+
+func (r *logViewRenderer) MinSize() fyne.Size {
+	return r.view.scroll.MinSize()
+}
+
+func (r *logViewRenderer) Refresh() {
+	r.view.content.Refresh()
+}
+
+func (r *logViewRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.view.scroll}
+}
+
+func (r *logViewRenderer) Destroy() {
+}
+
+// get the width of a character and the height of a line of monospace log text
+func getLogCellSize() (float32, float32) {
+	size := fyne.MeasureText("M", theme.TextSize(), fyne.TextStyle{Monospace: true})
+	return size.Width, size.Height
+}
+
+// logContent is the scrolled content of a LogView, as tall as all lines but drawing only the visible ones
+type logContent struct {
+	widget.BaseWidget
+
+	view *LogView
+}
+
+func (c *logContent) CreateRenderer() fyne.WidgetRenderer {
+	return &logContentRenderer{content: c}
+}
+
+func (c *logContent) MouseDown(e *desktop.MouseEvent) {
+	c.view.selectLineAt(e.Position.Y, e.Modifier&fyne.KeyModifierShift != 0)
+	if cnv := fyne.CurrentApp().Driver().CanvasForObject(c); cnv != nil {
+		cnv.Focus(c)
+	}
+}
+
+func (c *logContent) MouseUp(*desktop.MouseEvent) {
+}
+
+func (c *logContent) Dragged(e *fyne.DragEvent) {
+	c.view.selectLineAt(e.Position.Y, true)
+}
+
+func (c *logContent) DragEnd() {
+}
+
+// focus is needed for the copy shortcut
+func (c *logContent) FocusGained() {
+}
+
+func (c *logContent) FocusLost() {
+}
+
+func (c *logContent) TypedRune(rune) {
+}
+
+func (c *logContent) TypedKey(*fyne.KeyEvent) {
+}
+
+func (c *logContent) TypedShortcut(shortcut fyne.Shortcut) {
+	switch s := shortcut.(type) {
+	case *fyne.ShortcutCopy:
+		s.Clipboard.SetContent(c.view.SelectedText())
+	case *fyne.ShortcutSelectAll:
+		c.view.mutex.Lock()
+		if c.view.buffer.count > 0 {
+			c.view.selectionAnchor = c.view.buffer.dropped + 1
+			c.view.selectionEnd = c.view.buffer.dropped + c.view.buffer.count
+		}
+		c.view.mutex.Unlock()
+		c.Refresh()
+	}
+}
+
+// one drawn line: selection background, text backgrounds, search match highlights, line number,
+// text segments and underlines. The pools grow to the most styled segments drawn in a line.
+type logRow struct {
+	background  *canvas.Rectangle
+	backgrounds []*canvas.Rectangle
+	highlights  []*canvas.Rectangle
+	number      *canvas.Text
+	texts       []*canvas.Text
+	underlines  []*canvas.Rectangle
+}
+
+type logContentRenderer struct {
+	content *logContent
+	rows    []*logRow
+	objects []fyne.CanvasObject
+}
+
+func (r *logContentRenderer) Layout(fyne.Size) {
+	r.update()
+}
+
+func (r *logContentRenderer) MinSize() fyne.Size {
+	charWidth, lineHeight := getLogCellSize()
+	v := r.content.view
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	gutter := theme.Padding()
+	if v.lineNumbers {
+		gutter = float32(getLineNumberDigits(v.buffer.dropped+v.buffer.count)+1) * charWidth
+	}
+	longest := fyne.Min(float32(v.buffer.longest), maxLogLineDisplay)
+	return fyne.NewSize(gutter+longest*charWidth+theme.Padding()*2, float32(v.rowCount())*lineHeight)
+}
+
+func (r *logContentRenderer) Refresh() {
+	r.update()
+	canvas.Refresh(r.content)
+}
+
+func (r *logContentRenderer) Objects() []fyne.CanvasObject {
+	return r.objects
+}
+
+func (r *logContentRenderer) Destroy() {
+}
+
+// place the visible lines at the scroll offset of the view
+func (r *logContentRenderer) update() {
+	v := r.content.view
+	charWidth, lineHeight := getLogCellSize()
+	first := int(v.scroll.Offset.Y / lineHeight)
+	visible := int(v.scroll.Size().Height/lineHeight) + 2
+
+	objectsAdded := false
+	for len(r.rows) < visible {
+		row := &logRow{
+			background: canvas.NewRectangle(theme.SelectionColor()),
+			number:     canvas.NewText("", theme.DisabledColor()),
+		}
+		row.number.TextStyle = fyne.TextStyle{Monospace: true}
+		row.number.Alignment = fyne.TextAlignTrailing
+		r.rows = append(r.rows, row)
+		objectsAdded = true
+	}
+
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	gutter, textX := float32(0), theme.Padding()
+	if v.lineNumbers {
+		gutter = float32(getLineNumberDigits(v.buffer.dropped+v.buffer.count)) * charWidth
+		textX = gutter + charWidth
+	}
+	width := fyne.Max(r.content.Size().Width, v.scroll.Size().Width)
+	selectionFirst, selectionLast := v.selection()
+	rows := v.rowCount()
+
+	for i, row := range r.rows {
+		index := first + i
+		if i >= visible || index >= rows {
+			row.background.Hide()
+			row.number.Hide()
+			hideRectangles(row.backgrounds)
+			hideRectangles(row.highlights)
+			hideTexts(row.texts)
+			hideRectangles(row.underlines)
+			continue
+		}
+		lineNumber := v.rowLine(index)
+		y := float32(index) * lineHeight
+		text, segments := getDisplaySegments(v.lineText(lineNumber), v.colors)
+
+		if v.lineNumbers {
+			row.number.Text = strconv.Itoa(lineNumber)
+			row.number.Color = theme.DisabledColor()
+			row.number.Move(fyne.NewPos(0, y))
+			row.number.Resize(fyne.NewSize(gutter, lineHeight))
+			row.number.Show()
+			row.number.Refresh()
+		} else {
+			row.number.Hide()
+		}
+
+		if v.selectionAnchor != 0 && lineNumber >= selectionFirst && lineNumber <= selectionLast {
+			row.background.FillColor = theme.SelectionColor()
+			row.background.Move(fyne.NewPos(0, y))
+			row.background.Resize(fyne.NewSize(width, lineHeight))
+			row.background.Show()
+			row.background.Refresh()
+		} else {
+			row.background.Hide()
+		}
+
+		// a text for every styled segment, with its background and underline
+		backgrounds, texts, underlines := 0, 0, 0
+		column := 0
+		for _, segment := range segments {
+			length := utf8.RuneCountInString(segment.Text)
+			position := fyne.NewPos(textX+float32(column)*charWidth, y)
+			size := fyne.NewSize(float32(length)*charWidth, lineHeight)
+			column += length
+			foreground, background := getANSIColors(segment.Style)
+
+			if background != nil {
+				rectangle := getPoolRectangle(&row.backgrounds, backgrounds, &objectsAdded)
+				backgrounds++
+				rectangle.FillColor = background
+				rectangle.Move(position)
+				rectangle.Resize(size)
+				rectangle.Show()
+				rectangle.Refresh()
+			}
+
+			segmentText := getPoolText(&row.texts, texts, &objectsAdded)
+			texts++
+			segmentText.Text = segment.Text
+			segmentText.Color = foreground
+			segmentText.TextStyle = fyne.TextStyle{Monospace: true, Bold: segment.Style.Bold, Italic: segment.Style.Italic}
+			segmentText.Move(position)
+			segmentText.Resize(size)
+			segmentText.Show()
+			segmentText.Refresh()
+
+			if segment.Style.Underline {
+				underline := getPoolRectangle(&row.underlines, underlines, &objectsAdded)
+				underlines++
+				underline.FillColor = foreground
+				underline.Move(fyne.NewPos(position.X, y+lineHeight-2))
+				underline.Resize(fyne.NewSize(size.Width, 1))
+				underline.Show()
+				underline.Refresh()
+			}
+		}
+		hideRectangles(row.backgrounds[backgrounds:])
+		hideTexts(row.texts[texts:])
+		hideRectangles(row.underlines[underlines:])
+
+		// the current match is highlighted stronger than the other matches
+		var matches [][]int
+		if v.search != nil {
+			matches = v.search.FindAllStringIndex(text, -1)
+		}
+		highlightColor := withAlpha(theme.WarningColor(), 0x60)
+		if lineNumber == v.currentMatch {
+			highlightColor = withAlpha(theme.PrimaryColor(), 0xa0)
+		}
+		highlights := 0
+		for _, match := range matches {
+			if match[0] == match[1] {
+				continue
+			}
+			highlight := getPoolRectangle(&row.highlights, highlights, &objectsAdded)
+			highlights++
+			highlight.FillColor = highlightColor
+			column := utf8.RuneCountInString(text[:match[0]])
+			length := utf8.RuneCountInString(text[match[0]:match[1]])
+			highlight.Move(fyne.NewPos(textX+float32(column)*charWidth, y))
+			highlight.Resize(fyne.NewSize(float32(length)*charWidth, lineHeight))
+			highlight.Show()
+			highlight.Refresh()
+		}
+		hideRectangles(row.highlights[highlights:])
+	}
+
+	if objectsAdded {
+		r.updateObjects()
+	}
+}
+
+// list the objects of all rows, backgrounds and highlights below the text
+func (r *logContentRenderer) updateObjects() {
+	r.objects = r.objects[:0]
+	for _, row := range r.rows {
+		r.objects = append(r.objects, row.background)
+		for _, background := range row.backgrounds {
+			r.objects = append(r.objects, background)
+		}
+		for _, highlight := range row.highlights {
+			r.objects = append(r.objects, highlight)
+		}
+		r.objects = append(r.objects, row.number)
+		for _, text := range row.texts {
+			r.objects = append(r.objects, text)
+		}
+		for _, underline := range row.underlines {
+			r.objects = append(r.objects, underline)
+		}
+	}
+}
+
+// get the rectangle at index i of a pool, growing the pool when needed
+func getPoolRectangle(pool *[]*canvas.Rectangle, i int, added *bool) *canvas.Rectangle {
+	if i == len(*pool) {
+		*pool = append(*pool, canvas.NewRectangle(color.Transparent))
+		*added = true
+	}
+	return (*pool)[i]
+}
+
+// get the text at index i of a pool, growing the pool when needed
+func getPoolText(pool *[]*canvas.Text, i int, added *bool) *canvas.Text {
+	if i == len(*pool) {
+		*pool = append(*pool, canvas.NewText("", theme.ForegroundColor()))
+		*added = true
+	}
+	return (*pool)[i]
+}
+
+func hideRectangles(rectangles []*canvas.Rectangle) {
+	for _, rectangle := range rectangles {
+		rectangle.Hide()
+	}
+}
+
+func hideTexts(texts []*canvas.Text) {
+	for _, text := range texts {
+		text.Hide()
+	}
+}
+
+// get the text and background color of an ANSI style, the background is nil for the default background
+func getANSIColors(style utils.ANSIStyle) (color.Color, color.Color) {
+	var foreground, background color.Color = theme.ForegroundColor(), nil
+	if style.Foreground.Set {
+		ansiColor := style.Foreground
+		// bold basic colors are shown bright like most terminals do
+		if style.Bold && !ansiColor.RGB && ansiColor.Index < 8 {
+			ansiColor.Index += 8
+		}
+		foreground = ansiColor.NRGBA()
+	}
+	if style.Background.Set {
+		background = style.Background.NRGBA()
+	}
+	if style.Inverse {
+		inverseBackground := foreground
+		foreground = theme.BackgroundColor()
+		if background != nil {
+			foreground = background
+		}
+		background = inverseBackground
+	}
+	if style.Faint {
+		foreground = withAlpha(foreground, 0x99)
+	}
+	return foreground, background
+}
+
+func withAlpha(c color.Color, alpha uint8) color.Color {
+	red, green, blue, _ := c.RGBA()
+	return color.NRGBA{R: uint8(red >> 8), G: uint8(green >> 8), B: uint8(blue >> 8), A: alpha}
+}
+
+// width of the line number gutter in digits
+func getLineNumberDigits(lastLine int) int {
+	if digits := len(strconv.Itoa(lastLine)); digits > 4 {
+		return digits
+	}
+	return 4
+}
+
+// get the text of a line for drawing and its styled segments: escape sequences are removed (their
+// styles kept when colors is set), tabs are expanded and very long lines are cut
+func getDisplaySegments(line string, colors bool) (string, []utils.ANSISegment) {
+	segments := []utils.ANSISegment{{Text: line}}
+	if strings.IndexByte(line, '\x1b') >= 0 {
+		segments = utils.ParseANSI(line)
+		if !colors {
+			segments = []utils.ANSISegment{{Text: utils.RemoveANSIEscapeCodes(line)}}
+		}
+	}
+
+	var text strings.Builder
+	display := make([]utils.ANSISegment, 0, len(segments))
+	remaining := maxLogLineDisplay
+	for _, segment := range segments {
+		segment.Text = strings.ReplaceAll(segment.Text, "\t", "    ")
+		length := utf8.RuneCountInString(segment.Text)
+		if length > remaining {
+			segment.Text = string([]rune(segment.Text)[:remaining])
+			display = append(display, segment, utils.ANSISegment{Text: "…"})
+			text.WriteString(segment.Text + "…")
+			break
+		}
+		remaining -= length
+		display = append(display, segment)
+		text.WriteString(segment.Text)
+	}
+	return text.String(), display
+}
+
+// remove the escape sequences from lines, in place
+func getPlainLines(lines []string) []string {
+	for i, line := range lines {
+		lines[i] = utils.RemoveANSIEscapeCodes(line)
+	}
+	return lines
+}
+
+// logBuffer keeps the last lines of a log in a ring
+type logBuffer struct {
+	lines    []string
+	capacity int
+	start    int
+	count    int
+	// lines dropped from the front, keeps line numbers stable
+	dropped int
+	// longest line in runes, for the scroll width
+	longest int
+}
+
+func newLogBuffer(capacity int) *logBuffer {
+	return &logBuffer{capacity: capacity}
+}
+
+func (b *logBuffer) reset() {
+	b.lines, b.start, b.count, b.dropped, b.longest = nil, 0, 0, 0, 0
+}
+
+func (b *logBuffer) append(lines []string) {
+	for _, line := range lines {
+		if length := utf8.RuneCountInString(utils.RemoveANSIEscapeCodes(line)); length > b.longest {
+			b.longest = length
+		}
+		if len(b.lines) < b.capacity {
+			b.lines = append(b.lines, line)
+			b.count++
+			continue
+		}
+		// full, overwrite the oldest line
+		b.lines[b.start] = line
+		b.start = (b.start + 1) % b.capacity
+		b.dropped++
+	}
+}
+
+// get the line at index, 0 is the oldest line kept
+func (b *logBuffer) line(index int) string {
+	return b.lines[(b.start+index)%len(b.lines)]
+}
+
+// copy the lines from index from up to index to
+func (b *logBuffer) slice(from int, to int) []string {
+	lines := make([]string, 0, to-from)
+	for i := from; i < to; i++ {
+		lines = append(lines, b.line(i))
+	}
+	return lines
+}
@@ -0,0 +1,318 @@
+package ui
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/michaeljsaenz/kview/internal/k8s"
+)
+
+// pod table columns and their initial widths
+var (
+	podColumns      = []string{"Name", "Ready", "Status", "Restarts", "Age", "IP", "Node", "QoS"}
+	podColumnWidths = []float32{260, 80, 150, 100, 70, 120, 200, 110}
+)
+
+const (
+	podColumnName = iota
+	podColumnReady
+	podColumnStatus
+	podColumnRestarts
+	podColumnAge
+	podColumnIP
+	podColumnNode
+	podColumnQoS
+)
+
+// narrowest a column can be dragged
+const minColumnWidth = 40
+
+// PodTable lists pods with the columns of kubectl get pods -o wide. Tapping a header sorts by
+// that column, dragging it resizes the column. Rows are bound to the pod names in podData,
+// so the list works like the plain pod list for selection.
+type PodTable struct {
+	Content fyne.CanvasObject
+	Data    binding.ExternalStringList
+	List    *widget.List
+	// called after the rows were sorted again
+	OnSorted func()
+
+	widths         []float32
+	header         *fyne.Container
+	headers        []*columnHeader
+	rows           []*fyne.Container
+	sortColumn     int
+	sortDescending bool
+	summaryMutex   sync.RWMutex
+	summaries      map[string]k8s.PodSummary
+}
+
+func NewPodTable(podData *[]string) *PodTable {
+	t := &PodTable{summaries: make(map[string]k8s.PodSummary)}
+	t.widths = append([]float32(nil), podColumnWidths...)
+
+	// list binding, bind pod list data to data
+	t.Data = binding.BindStringList(podData)
+	t.List = widget.NewListWithData(t.Data,
+		func() fyne.CanvasObject {
+			cells := make([]fyne.CanvasObject, len(podColumns))
+			for i := range cells {
+				cell := widget.NewRichText(&widget.TextSegment{Style: widget.RichTextStyleInline})
+				cell.Wrapping = fyne.TextTruncate
+				cells[i] = cell
+			}
+			row := container.New(&columnLayout{widths: &t.widths}, cells...)
+			t.rows = append(t.rows, row)
+			return row
+		},
+		func(i binding.DataItem, o fyne.CanvasObject) {
+			podName, _ := i.(binding.String).Get()
+			t.updateRow(podName, o.(*fyne.Container).Objects)
+		})
+
+	for i, columnName := range podColumns {
+		column := i
+		t.headers = append(t.headers, newColumnHeader(columnName, func() { t.sortBy(column) },
+			func(dx float32) { t.resizeColumn(column, dx) }))
+	}
+	t.header = container.New(&columnLayout{widths: &t.widths}, headerObjects(t.headers)...)
+	t.updateHeaders()
+
+	// header and rows scroll sideways together
+	t.Content = container.NewHScroll(container.NewBorder(t.header, nil, nil, nil, t.List))
+	return t
+}
+
+func headerObjects(headers []*columnHeader) []fyne.CanvasObject {
+	objects := make([]fyne.CanvasObject, len(headers))
+	for i, h := range headers {
+		objects[i] = h
+	}
+	return objects
+}
+
+// SetPods replaces the pod rows and returns the pod names in table order
+func (t *PodTable) SetPods(podSummaries []k8s.PodSummary) []string {
+	summaries := make(map[string]k8s.PodSummary, len(podSummaries))
+	podNames := make([]string, 0, len(podSummaries))
+	for _, summary := range podSummaries {
+		summaries[summary.Name] = summary
+		podNames = append(podNames, summary.Name)
+	}
+	t.summaryMutex.Lock()
+	t.summaries = summaries
+	t.summaryMutex.Unlock()
+
+	t.sortPodNames(podNames)
+	return podNames
+}
+
+func (t *PodTable) getSummary(podName string) k8s.PodSummary {
+	t.summaryMutex.RLock()
+	defer t.summaryMutex.RUnlock()
+	if summary, ok := t.summaries[podName]; ok {
+		return summary
+	}
+	return k8s.PodSummary{Name: podName}
+}
+
+// tapping the sorted column again reverses the order
+func (t *PodTable) sortBy(column int) {
+	if column == t.sortColumn {
+		t.sortDescending = !t.sortDescending
+	} else {
+		t.sortColumn, t.sortDescending = column, false
+	}
+	t.updateHeaders()
+
+	// sort a copy, the rows are only replaced through the binding
+	podNames, _ := t.Data.Get()
+	podNames = append([]string(nil), podNames...)
+	t.sortPodNames(podNames)
+	t.Data.Set(podNames)
+	if t.OnSorted != nil {
+		t.OnSorted()
+	}
+}
+
+func (t *PodTable) sortPodNames(podNames []string) {
+	sort.SliceStable(podNames, func(i, j int) bool {
+		a, b := t.getSummary(podNames[i]), t.getSummary(podNames[j])
+		var less, greater bool
+		switch t.sortColumn {
+		case podColumnReady:
+			less, greater = a.ReadyContainers < b.ReadyContainers, a.ReadyContainers > b.ReadyContainers
+		case podColumnStatus:
+			less, greater = a.Status < b.Status, a.Status > b.Status
+		case podColumnRestarts:
+			less, greater = a.Restarts < b.Restarts, a.Restarts > b.Restarts
+		case podColumnAge:
+			// youngest first
+			less, greater = a.CreationTime.After(b.CreationTime), a.CreationTime.Before(b.CreationTime)
+		case podColumnIP:
+			less, greater = a.IP < b.IP, a.IP > b.IP
+		case podColumnNode:
+			less, greater = a.Node < b.Node, a.Node > b.Node
+		case podColumnQoS:
+			less, greater = a.QoS < b.QoS, a.QoS > b.QoS
+		}
+		// equal rows are ordered by name
+		if !less && !greater {
+			less, greater = a.Name < b.Name, a.Name > b.Name
+		}
+		if t.sortDescending {
+			return greater
+		}
+		return less
+	})
+}
+
+func (t *PodTable) updateHeaders() {
+	for i, h := range t.headers {
+		switch {
+		case i != t.sortColumn:
+			h.icon.Hide()
+			continue
+		case t.sortDescending:
+			h.icon.SetResource(theme.MenuDropDownIcon())
+		default:
+			h.icon.SetResource(theme.MenuDropUpIcon())
+		}
+		h.icon.Show()
+	}
+}
+
+func (t *PodTable) resizeColumn(column int, dx float32) {
+	t.widths[column] = fyne.Max(minColumnWidth, t.widths[column]+dx)
+	t.header.Refresh()
+	t.header.Layout.Layout(t.header.Objects, t.header.Size())
+	for _, row := range t.rows {
+		row.Layout.Layout(row.Objects, row.Size())
+	}
+	t.List.Refresh()
+	t.Content.Refresh()
+}
+
+func (t *PodTable) updateRow(podName string, cells []fyne.CanvasObject) {
+	summary := t.getSummary(podName)
+
+	values := make([]string, len(podColumns))
+	colors := make([]fyne.ThemeColorName, len(podColumns))
+	values[podColumnName] = summary.Name
+	values[podColumnStatus] = summary.Status
+	values[podColumnIP] = summary.IP
+	values[podColumnNode] = summary.Node
+	values[podColumnQoS] = summary.QoS
+	if summary.Containers > 0 {
+		values[podColumnReady] = strconv.Itoa(summary.ReadyContainers) + "/" + strconv.Itoa(summary.Containers)
+		values[podColumnRestarts] = strconv.Itoa(summary.Restarts)
+	}
+	if !summary.CreationTime.IsZero() {
+		values[podColumnAge] = k8s.GetAge(summary.CreationTime)
+	}
+
+	colors[podColumnStatus] = getHealthColor(summary.Health)
+	if summary.ReadyContainers < summary.Containers && summary.Health != k8s.PodCompleted {
+		colors[podColumnReady] = theme.ColorNameWarning
+	}
+	if summary.Restarts > 0 {
+		colors[podColumnRestarts] = theme.ColorNameWarning
+	}
+
+	for i, cell := range cells {
+		richText := cell.(*widget.RichText)
+		segment := richText.Segments[0].(*widget.TextSegment)
+		segment.Text = values[i]
+		segment.Style.ColorName = colors[i]
+		richText.Refresh()
+	}
+}
+
+func getHealthColor(health k8s.PodHealth) fyne.ThemeColorName {
+	switch health {
+	case k8s.PodHealthy:
+		return theme.ColorNameSuccess
+	case k8s.PodProgressing:
+		return theme.ColorNameWarning
+	case k8s.PodFailing:
+		return theme.ColorNameError
+	case k8s.PodCompleted:
+		return theme.ColorNameDisabled
+	}
+	return theme.ColorNameForeground
+}
+
+// columnLayout places the cells of a row at the table column widths
+type columnLayout struct {
+	widths *[]float32
+}
+
+func (l *columnLayout) Layout(objects []fyne.CanvasObject, size fyne.Size) {
+	x := float32(0)
+	for i, o := range objects {
+		width := (*l.widths)[i]
+		// the last column takes the remaining space
+		if i == len(objects)-1 {
+			width = fyne.Max(width, size.Width-x)
+		}
+		o.Move(fyne.NewPos(x, 0))
+		o.Resize(fyne.NewSize(width, size.Height))
+		x += width
+	}
+}
+
+func (l *columnLayout) MinSize(objects []fyne.CanvasObject) fyne.Size {
+	min := fyne.NewSize(0, 0)
+	for i, o := range objects {
+		min.Width += (*l.widths)[i]
+		min.Height = fyne.Max(min.Height, o.MinSize().Height)
+	}
+	return min
+}
+
+// columnHeader sorts its column when tapped and resizes it when dragged, onTapped is optional
+type columnHeader struct {
+	widget.BaseWidget
+
+	label     *widget.Label
+	icon      *widget.Icon
+	onTapped  func()
+	onDragged func(dx float32)
+}
+
+func newColumnHeader(name string, onTapped func(), onDragged func(dx float32)) *columnHeader {
+	h := &columnHeader{label: widget.NewLabel(name), icon: widget.NewIcon(nil), onTapped: onTapped, onDragged: onDragged}
+	h.label.TextStyle = fyne.TextStyle{Bold: true}
+	h.label.Wrapping = fyne.TextTruncate
+	h.icon.Hide()
+	h.ExtendBaseWidget(h)
+	return h
+}
+
+func (h *columnHeader) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(container.NewBorder(nil, nil, nil, h.icon, h.label))
+}
+
+func (h *columnHeader) Tapped(*fyne.PointEvent) {
+	if h.onTapped != nil {
+		h.onTapped()
+	}
+}
+
+func (h *columnHeader) Dragged(e *fyne.DragEvent) {
+	h.onDragged(e.Dragged.DX)
+}
+
+func (h *columnHeader) DragEnd() {
+}
+
+func (h *columnHeader) Cursor() desktop.Cursor {
+	return desktop.HResizeCursor
+}
@@ -0,0 +1,551 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/michaeljsaenz/kview/internal/k8s"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// wait for pod events to settle before reloading the pod list
+const podListDebounce = 250 * time.Millisecond
+
+// Session is the pod list and detail view bound to one cluster context,
+// each session has its own clientset
+type Session struct {
+	Content fyne.CanvasObject
+	// follow the kubeconfig current-context, set for the first session only
+	Following bool
+	// called after the session switched to another context
+	OnContextChanged func(s *Session)
+	// called after the alias or color of the session context was edited
+	OnDisplayChanged func()
+
+	app fyne.App
+	win fyne.Window
+	// cluster context of the session, switched from the kubeconfig watch
+	contextMutex sync.RWMutex
+	contextName  string
+	clientMutex  sync.RWMutex
+	clientset    *kubernetes.Clientset
+	config       *rest.Config
+	switchMutex  sync.Mutex
+	connected    bool
+	// rows of the pod list, written through data so the list binding reads them under its lock
+	podData  []string
+	podTable *PodTable
+	// pods shown in the detail views, read from the pod watch when there is one
+	podCache *k8s.PodCache
+	// API calls of the namespace (pod list) and pod (detail views) selections
+	namespaceLoader *Loader
+	podLoader       *Loader
+
+	// live pod list of the selected namespace
+	watchMutex  sync.Mutex
+	podWatcher  *k8s.PodWatcher
+	reloadTimer *time.Timer
+	// pod events arrive on the watch goroutine, selectionMutex guards the selected pod and its updates
+	selectionMutex   sync.Mutex
+	selectedPod      string
+	syncingSelection atomic.Bool
+
+	data                  binding.ExternalStringList
+	list                  *widget.List
+	input                 *widget.Entry
+	contextBar            *canvas.Rectangle
+	topWindowLabel        *canvas.Text
+	contextDropdown       *ContextDropdown
+	namespaceListDropdown *widget.Select
+	banner                *fyne.Container
+	bannerLabel           *widget.Label
+	statusBar             *StatusBar
+	rightWindowTitle      *widget.Label
+	podStatus             *widget.Label
+	podLabels             *widget.Label
+	podAnnotations        *widget.Label
+	podEvents             *widget.Label
+	podVolumes            *widget.Label
+	podLog                *widget.Label
+	podDetailLog          *widget.Label
+	podLogsLabel          *widget.Label
+	podLogScroll          *container.Scroll
+	podTabs               *container.AppTabs
+	podLogTabs            *container.AppTabs
+	yamlButton            *widget.Button
+	exportLogsButton      *widget.Button
+	execButtons           []*widget.Button
+	containerControls     *ContainerControls
+}
+
+// NewSession builds the session view and connects to contextName (empty name uses the
+// kubeconfig current-context), connection errors are shown in the session
+func NewSession(app fyne.App, win fyne.Window, contextName string, contexts []string, following bool) *Session {
+	s := &Session{app: app, win: win, Following: following, podCache: k8s.NewPodCache()}
+
+	if contextName == "" {
+		contextName, _ = k8s.GetCurrentContext()
+	}
+	s.contextName = contextName
+
+	// pod table, rows bound to the pod list (podData)
+	s.podTable = NewPodTable(&s.podData)
+	s.data, s.list = s.podTable.Data, s.podTable.List
+	// keep the selected pod selected when the rows are sorted
+	s.podTable.OnSorted = s.restoreSelection
+
+	// context switcher, retargets this session
+	s.contextDropdown = CreateContextDropdown(app.Preferences(), contexts, func(selectedContext string) {
+		go s.SwitchContext(selectedContext, "selected in kview")
+	})
+
+	// context alias and color settings
+	contextSettings := widget.NewButtonWithIcon("", theme.SettingsIcon(), func() {
+		ShowContextDisplayDialog(app.Preferences(), s.Context(), win, func() {
+			if s.OnDisplayChanged != nil {
+				s.OnDisplayChanged()
+			}
+		})
+	})
+
+	// intial/base widgets and windows
+	topWindowLabel, topWindow, rightWindow, rightWindowTitle := CreateWindows(GetContextDisplayName(app.Preferences(), contextName),
+		s.contextDropdown.Select, contextSettings)
+	s.topWindowLabel, s.rightWindowTitle = topWindowLabel, rightWindowTitle
+	s.contextBar = canvas.NewRectangle(GetContextColor(app.Preferences(), contextName))
+	s.contextBar.SetMinSize(fyne.NewSize(0, 4))
+	topWindowLabel.Color = GetContextColor(app.Preferences(), contextName)
+
+	// banner announcing cluster context switches
+	s.banner, s.bannerLabel = CreateBanner()
+
+	// status bar with the last error and a retry action
+	s.statusBar = NewStatusBar(win)
+
+	// activity bars shown while the pod list or pod detail is loading
+	listActivity, setListBusy := CreateActivity()
+	podActivity, setPodBusy := CreateActivity()
+	s.namespaceLoader = NewLoader(setListBusy)
+	s.podLoader = NewLoader(setPodBusy)
+
+	podStatus, input, listTitle := CreateBaseWidgets()
+	s.podStatus, s.input = podStatus, input
+
+	podLabelsLabel, podLabels, podLabelsScroll, podAnnotationsLabel, podAnnotations, podAnnotationsScroll,
+		podEventsLabel, podEvents, podEventsScroll, podLogsLabel, podLog, podLogScroll, podDetailLabel, podDetailLog, podDetailScroll,
+		podVolumesLabel, podVolumes, podVolumesScroll := CreateBaseTabs()
+	s.podLabels, s.podAnnotations, s.podEvents, s.podVolumes = podLabels, podAnnotations, podEvents, podVolumes
+	s.podLog, s.podDetailLog, s.podLogsLabel, s.podLogScroll = podLog, podDetailLog, podLogsLabel, podLogScroll
+
+	s.podTabs, s.podLogTabs = CreateBaseTabContainers(podLabelsLabel, podLabelsScroll, podAnnotationsLabel, podAnnotationsScroll,
+		podEventsLabel, podEventsScroll, podLogsLabel, podLogScroll, podDetailLabel, podDetailScroll, podVolumesLabel, podVolumesScroll)
+
+	// create the namespace dropdown list widget
+	s.namespaceListDropdown = widget.NewSelect(nil, func(selectedNamespace string) {
+		s.stopPodWatch()
+		ctx := s.namespaceLoader.Reset()
+		s.data.Set([]string{})
+		UpdateInput(s.input, s.data, s.list)
+		if selectedNamespace != "" {
+			s.watchPods(ctx, selectedNamespace)
+		}
+	})
+	s.namespaceListDropdown.PlaceHolder = "Select namespace..."
+	s.namespaceListDropdown.FocusGained()
+
+	// refresh and clear pod list data
+	refresh := widget.NewButtonWithIcon("Refresh", theme.ViewRefreshIcon(), func() {
+		if !s.isConnected() {
+			go s.Connect()
+			return
+		}
+		s.input.Text = ""
+		s.input.Refresh()
+		s.data.Set([]string{})
+		if watcher := s.getPodWatcher(); watcher != nil {
+			s.data.Set(s.podTable.SetPods(watcher.PodSummaries()))
+		} else if s.namespaceListDropdown.Selected != "" {
+			s.watchPods(s.namespaceLoader.Reset(), s.namespaceListDropdown.Selected)
+		}
+
+		RefreshData(s.input, s.data, s.list, s.podTabs, s.podLogTabs, s.podLogsLabel, s.podStatus, s.rightWindowTitle)
+
+	})
+
+	// search application name (input list field)
+	s.input.OnSubmitted = func(string) {
+		if watcher := s.getPodWatcher(); watcher != nil {
+			s.data.Set(InputOnSubmitted(s.input, s.podTable.SetPods(watcher.PodSummaries())))
+		} else if s.namespaceListDropdown.Selected != "" {
+			// no live list, list again and filter once loaded
+			s.watchPods(s.namespaceLoader.Reset(), s.namespaceListDropdown.Selected)
+			return
+		}
+		s.data.Reload()
+		s.list.UnselectAll()
+	}
+
+	s.yamlButton = CreateIconButton("Application (Pod) YAML", theme.ZoomInIcon())
+	s.yamlButton.Hide()
+	s.exportLogsButton = CreateIconButton("Export All Logs", theme.DownloadIcon())
+	s.exportLogsButton.Hide()
+
+	s.execButtons = CreateBaseExecIconButton("", theme.LoginIcon())
+	for _, execButton := range s.execButtons {
+		execButton.Hide()
+	}
+	s.containerControls = NewContainerControls(s.podLogTabs, s.execButtons)
+
+	gridOne := container.New(layout.NewGridLayoutWithColumns(2), s.yamlButton, s.exportLogsButton)
+	gridTwo := container.New(layout.NewGridLayoutWithColumns(2), s.execButtons[0], s.execButtons[1], s.execButtons[2],
+		s.execButtons[3], s.execButtons[4], s.execButtons[5], s.execButtons[6], s.execButtons[7], s.execButtons[8], s.execButtons[9])
+
+	//return tabs to initial tab (index 0)
+	s.list.OnUnselected = func(id widget.ListItemID) {
+		// keep the detail panes when the live list only moved the selection
+		if s.syncingSelection.Load() {
+			return
+		}
+		s.setSelectedPod("")
+		s.podLoader.Reset()
+		s.podTabs.SelectIndex(0)
+		s.podLogTabs.SelectIndex(0)
+		s.containerControls.Clear()
+	}
+
+	rightContainer := container.NewBorder(
+		container.NewVBox(s.rightWindowTitle, podActivity, s.podStatus, s.podTabs, s.podLogTabs, gridOne, gridTwo),
+		nil, nil, nil, rightWindow)
+
+	// logs of all pods matching a selector, pods filtered by name (a substring) are preselected
+	aggregatedLogs := widget.NewButtonWithIcon("Logs", theme.ListIcon(), func() {
+		clientset, _ := s.getClient()
+		if clientset == nil || s.namespaceListDropdown.Selected == "" {
+			dialog.ShowInformation("Aggregated Logs", "Select a namespace first", s.win)
+			return
+		}
+		ShowAggregatedLogs(s.app, *clientset, s.namespaceListDropdown.Selected, regexp.QuoteMeta(s.input.Text))
+	})
+	// run a command in all pods matching a selector, pods filtered by name (a substring) are preselected
+	fanOutExec := widget.NewButtonWithIcon("Exec", theme.ComputerIcon(), func() {
+		clientset, config := s.getClient()
+		if clientset == nil || config == nil || s.namespaceListDropdown.Selected == "" {
+			dialog.ShowInformation("Exec in Pods", "Select a namespace first", s.win)
+			return
+		}
+		ShowFanOutExec(s.app, *clientset, *config, s.namespaceListDropdown.Selected, regexp.QuoteMeta(s.input.Text))
+	})
+	namespaceBar := container.NewBorder(nil, nil, nil, container.NewHBox(aggregatedLogs, fanOutExec),
+		s.namespaceListDropdown)
+
+	listContainer := container.NewBorder(container.NewVBox(listTitle, namespaceBar, s.input, listActivity),
+		nil, nil, nil, s.podTable.Content)
+
+	// podData(list) left side, podData detail right side
+	split := container.NewHSplit(listContainer, rightContainer)
+	split.Offset = 0.3
+
+	s.Content = container.NewBorder(container.NewVBox(s.contextBar, topWindow, s.banner),
+		container.NewVBox(s.statusBar.Content, refresh), nil, nil, split)
+
+	s.Connect()
+
+	return s
+}
+
+// get the session clientset and config, nil when not connected
+func (s *Session) getClient() (*kubernetes.Clientset, *rest.Config) {
+	s.clientMutex.RLock()
+	defer s.clientMutex.RUnlock()
+	return s.clientset, s.config
+}
+
+func (s *Session) isConnected() bool {
+	s.clientMutex.RLock()
+	defer s.clientMutex.RUnlock()
+	return s.connected
+}
+
+// show a failed action in the status bar
+func (s *Session) showError(action string, err error, retry func()) {
+	s.statusBar.SetError(action, err, retry)
+}
+
+func (s *Session) listOnSelected() {
+	clientset, config := s.getClient()
+	ListOnSelected(s.list, s.data, *clientset, *config, s.podCache, s.podLoader, s.rightWindowTitle, s.podStatus, s.podLabels,
+		s.podAnnotations, s.podEvents, s.podVolumes, s.podLog, s.podDetailLog, s.podTabs, s.podLogTabs, s.podLogScroll,
+		s.podLogsLabel, s.app, s.yamlButton, s.exportLogsButton, s.execButtons, s.containerControls, s.namespaceListDropdown, s.showError)
+
+	// remember the selected pod to keep it selected when the live list changes
+	onSelected := s.list.OnSelected
+	s.list.OnSelected = func(id widget.ListItemID) {
+		if s.syncingSelection.Load() {
+			return
+		}
+		if podName, err := s.data.GetValue(id); err == nil {
+			s.setSelectedPod(podName)
+		}
+		onSelected(id)
+	}
+}
+
+func (s *Session) setSelectedPod(podName string) {
+	s.selectionMutex.Lock()
+	defer s.selectionMutex.Unlock()
+	s.selectedPod = podName
+}
+
+func (s *Session) getPodWatcher() *k8s.PodWatcher {
+	s.watchMutex.Lock()
+	defer s.watchMutex.Unlock()
+	return s.podWatcher
+}
+
+// watch the pods of namespace to keep the pod list live, falls back to a one-time list.
+// The watch ends when ctx, the namespace selection, is canceled.
+func (s *Session) watchPods(ctx context.Context, namespace string) {
+	clientset, _ := s.getClient()
+	if clientset == nil {
+		return
+	}
+	action := "Watch applications (pods) in " + namespace
+	retry := func() {
+		if s.namespaceListDropdown.Selected == namespace {
+			s.watchPods(s.namespaceLoader.Reset(), namespace)
+		}
+	}
+	s.namespaceLoader.Run(ctx, func(ctx context.Context) func() {
+		watcher, err := k8s.WatchPods(ctx, clientset, namespace, s.onPodEvent, func(err error) {
+			s.showError(action, err, nil)
+		})
+		if err != nil {
+			podSummaries, listErr := k8s.GetPodSummariesWithNamespace(ctx, *clientset, namespace)
+			return func() {
+				s.showError(action, err, retry)
+				if listErr != nil {
+					s.showError("List applications (pods) in "+namespace, listErr, retry)
+					return
+				}
+				s.data.Set(InputOnSubmitted(s.input, s.podTable.SetPods(podSummaries)))
+			}
+		}
+		go func() {
+			<-ctx.Done()
+			watcher.Stop()
+		}()
+
+		return func() {
+			s.watchMutex.Lock()
+			if s.podWatcher != nil {
+				s.podWatcher.Stop()
+			}
+			s.podWatcher = watcher
+			s.podCache.SetWatcher(watcher)
+			s.watchMutex.Unlock()
+
+			s.reloadPodList()
+		}
+	})
+}
+
+// stop the pod watch, only one namespace is watched at a time
+func (s *Session) stopPodWatch() {
+	s.watchMutex.Lock()
+	defer s.watchMutex.Unlock()
+	if s.podWatcher != nil {
+		s.podWatcher.Stop()
+		s.podWatcher = nil
+	}
+	s.podCache.SetWatcher(nil)
+	if s.reloadTimer != nil {
+		s.reloadTimer.Stop()
+	}
+}
+
+func (s *Session) onPodEvent(eventType k8s.PodEventType, pod *corev1.Pod) {
+	s.podCache.Invalidate(pod.Namespace, pod.Name)
+	s.selectionMutex.Lock()
+	if pod.Name == s.selectedPod {
+		if eventType == k8s.PodDeleted {
+			ShowBanner(s.banner, s.bannerLabel, "Application (pod) "+pod.Name+" was deleted.")
+		} else {
+			newPodStatus, newPodAge, newNodeName, containers := k8s.GetPodDetail(pod)
+			SetPodStatus(s.podStatus, newPodStatus, newPodAge, pod.Namespace, newNodeName)
+			// container states change, ephemeral containers can be added
+			s.containerControls.Show(containers)
+		}
+	}
+	s.selectionMutex.Unlock()
+
+	// coalesce bursts of events into one list reload
+	s.watchMutex.Lock()
+	defer s.watchMutex.Unlock()
+	if s.reloadTimer != nil {
+		s.reloadTimer.Stop()
+	}
+	s.reloadTimer = time.AfterFunc(podListDebounce, s.reloadPodList)
+}
+
+// reload the pod list from the watch cache, keeping the selected pod selected
+func (s *Session) reloadPodList() {
+	watcher := s.getPodWatcher()
+	if watcher == nil {
+		return
+	}
+	s.data.Set(InputOnSubmitted(s.input, s.podTable.SetPods(watcher.PodSummaries())))
+	s.restoreSelection()
+}
+
+// reload the pod rows and select the selected pod again, without reloading its details
+func (s *Session) restoreSelection() {
+	s.selectionMutex.Lock()
+	defer s.selectionMutex.Unlock()
+	// the list calls OnSelected and OnUnselected right away, they skip the selection synced here
+	s.syncingSelection.Store(true)
+	defer s.syncingSelection.Store(false)
+	s.data.Reload()
+	if s.selectedPod == "" {
+		return
+	}
+	podNames, _ := s.data.Get()
+	for id, podName := range podNames {
+		if podName == s.selectedPod {
+			s.list.Select(id)
+			return
+		}
+	}
+	s.list.UnselectAll()
+}
+
+// Close stops the background work of the session
+func (s *Session) Close() {
+	s.stopPodWatch()
+	s.namespaceLoader.Cancel()
+	s.podLoader.Cancel()
+}
+
+// Connect builds the clientset for the session context and loads namespaces in the background,
+// on failure the session is disabled and the error shown with a retry action
+func (s *Session) Connect() {
+	s.stopPodWatch()
+	s.podCache.Clear()
+	s.podLoader.Cancel()
+	ctx := s.namespaceLoader.Reset()
+	s.namespaceListDropdown.PlaceHolder = "Loading namespaces..."
+	s.namespaceListDropdown.Disable()
+
+	contextName := s.Context()
+	s.namespaceLoader.Run(ctx, func(ctx context.Context) func() {
+		clientset, config, err := k8s.GetClientSetForContext(contextName)
+		var namespaceList []string
+		var namespaceErr error
+		if err == nil {
+			namespaceList, namespaceErr = k8s.GetNamespaces(ctx, *clientset)
+			// without permission to list namespaces fall back to the context namespace
+			if k8s.ClassifyError(namespaceErr) == k8s.ErrorRBAC {
+				var namespace string
+				if namespace, err = k8s.GetContextNamespace(contextName); err == nil {
+					namespaceList = []string{namespace}
+				}
+			} else {
+				err = namespaceErr
+			}
+		}
+
+		return func() {
+			s.clientMutex.Lock()
+			s.clientset, s.config, s.connected = clientset, config, err == nil
+			s.clientMutex.Unlock()
+
+			s.namespaceListDropdown.PlaceHolder = "Select namespace..."
+			if err != nil {
+				s.namespaceListDropdown.Options = nil
+				s.namespaceListDropdown.ClearSelected()
+				SetupErrorUI("Connect to "+GetContextDisplayName(s.app.Preferences(), contextName), err,
+					s.namespaceListDropdown, s.input, s.statusBar, s.Connect)
+				return
+			}
+
+			s.statusBar.Clear()
+			if namespaceErr != nil {
+				s.showError("List namespaces", namespaceErr, nil)
+			}
+			s.namespaceListDropdown.Options = namespaceList
+			s.namespaceListDropdown.ClearSelected()
+			s.namespaceListDropdown.Enable()
+			s.input.Enable()
+			s.listOnSelected()
+		}
+	})
+}
+
+// SwitchContext rebuilds the session clientset for newContext and resets the pod views
+func (s *Session) SwitchContext(newContext string, reason string) {
+	s.switchMutex.Lock()
+	defer s.switchMutex.Unlock()
+	s.contextMutex.Lock()
+	previousContext := s.contextName
+	s.contextName = newContext
+	s.contextMutex.Unlock()
+	if newContext == previousContext {
+		if !s.isConnected() {
+			s.Connect()
+		}
+		return
+	}
+	s.RefreshContextDisplay()
+
+	// clear the pod list/detail panes and reconnect, namespaces are reloaded
+	s.data.Set([]string{})
+	RefreshData(s.input, s.data, s.list, s.podTabs, s.podLogTabs, s.podLogsLabel, s.podStatus, s.rightWindowTitle)
+	s.yamlButton.Hide()
+	s.exportLogsButton.Hide()
+	s.containerControls.Clear()
+	s.Connect()
+
+	prefs := s.app.Preferences()
+	ShowBanner(s.banner, s.bannerLabel, fmt.Sprintf("Cluster context switched from %s to %s (%s), pod list has been reset.",
+		GetContextDisplayName(prefs, previousContext), GetContextDisplayName(prefs, newContext), reason))
+
+	if s.OnContextChanged != nil {
+		s.OnContextChanged(s)
+	}
+}
+
+// Context returns the cluster context of the session
+func (s *Session) Context() string {
+	s.contextMutex.RLock()
+	defer s.contextMutex.RUnlock()
+	return s.contextName
+}
+
+// SetContexts updates the contexts offered by the session context switcher
+func (s *Session) SetContexts(contexts []string) {
+	s.contextDropdown.SetContexts(contexts)
+}
+
+// RefreshContextDisplay applies the current alias and color of the session context
+func (s *Session) RefreshContextDisplay() {
+	prefs := s.app.Preferences()
+	contextName := s.Context()
+	s.topWindowLabel.Text = ("Cluster Context: " + GetContextDisplayName(prefs, contextName))
+	s.topWindowLabel.Color = GetContextColor(prefs, contextName)
+	s.topWindowLabel.Refresh()
+	s.contextBar.FillColor = GetContextColor(prefs, contextName)
+	s.contextBar.Refresh()
+}
@@ -0,0 +1,322 @@
+package ui
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// fixed columns of the structured log view and their initial widths, field columns follow them
+var (
+	structuredLogColumns      = []string{"Time", "Level", "Message"}
+	structuredLogColumnWidths = []float32{230, 80, 520}
+)
+
+const (
+	// initial width of a field column
+	logFieldColumnWidth = 150
+	// lines checked to tell whether a log is structured
+	structuredLogSample = 100
+)
+
+// StructuredLogView shows JSON and logfmt log lines as a table of time, level, message and
+// selected fields, rows are colored by level. A filter like level=error keeps matching rows,
+// selecting a row shows the whole line pretty-printed.
+type StructuredLogView struct {
+	Content fyne.CanvasObject
+
+	list        *widget.List
+	header      *fyne.Container
+	rows        []*fyne.Container
+	filterEntry *widget.Entry
+	fieldsEntry *widget.Entry
+	fieldSelect *widget.Select
+	countLabel  *widget.Label
+	detail      *widget.Label
+
+	mutex   sync.RWMutex
+	records []logRecord
+	// indexes of the records matching the filter
+	shown   []int
+	filter  []logFilterTerm
+	columns []string
+	widths  []float32
+	// field keys of all records in order of appearance
+	keys    []string
+	keySeen map[string]bool
+}
+
+func NewStructuredLogView() *StructuredLogView {
+	v := &StructuredLogView{keySeen: make(map[string]bool)}
+	v.widths = append([]float32(nil), structuredLogColumnWidths...)
+
+	v.list = widget.NewList(
+		func() int {
+			v.mutex.RLock()
+			defer v.mutex.RUnlock()
+			return len(v.shown)
+		},
+		func() fyne.CanvasObject {
+			v.mutex.RLock()
+			cells := len(structuredLogColumns) + len(v.columns)
+			v.mutex.RUnlock()
+			row := container.New(&columnLayout{widths: &v.widths})
+			addLogCells(row, cells)
+			v.rows = append(v.rows, row)
+			return row
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			v.updateRow(id, o.(*fyne.Container))
+		})
+	v.list.OnSelected = v.showDetail
+	v.header = container.New(&columnLayout{widths: &v.widths})
+	v.updateHeader()
+
+	v.filterEntry = widget.NewEntry()
+	v.filterEntry.SetPlaceHolder("Filter: level=error user=alice timeout")
+	v.filterEntry.OnChanged = func(text string) {
+		v.mutex.Lock()
+		v.filter = parseLogFilter(text)
+		v.mutex.Unlock()
+		v.applyFilter()
+	}
+	v.fieldsEntry = widget.NewEntry()
+	v.fieldsEntry.SetPlaceHolder("Fields: request_id, user")
+	v.fieldsEntry.OnChanged = v.setColumns
+	v.fieldSelect = widget.NewSelect(nil, nil)
+	v.fieldSelect.PlaceHolder = "Add field"
+	v.fieldSelect.OnChanged = func(key string) {
+		if key == "" {
+			return
+		}
+		fields := strings.TrimSpace(v.fieldsEntry.Text)
+		if fields != "" {
+			fields += ", "
+		}
+		v.fieldsEntry.SetText(fields + key)
+		v.fieldSelect.ClearSelected()
+	}
+	v.countLabel = widget.NewLabel("")
+
+	v.detail = widget.NewLabel("Select a line to show all its fields")
+	v.detail.TextStyle = fyne.TextStyle{Monospace: true}
+
+	toolbar := container.NewBorder(nil, nil, nil, container.NewHBox(v.fieldSelect, v.countLabel),
+		container.NewGridWithColumns(2, v.filterEntry, v.fieldsEntry))
+	// header and rows scroll sideways together
+	table := container.NewHScroll(container.NewBorder(v.header, nil, nil, nil, v.list))
+	split := container.NewVSplit(table, container.NewScroll(v.detail))
+	split.Offset = 0.7
+	v.Content = container.NewBorder(toolbar, nil, nil, nil, split)
+	return v
+}
+
+// SetLines replaces the log lines
+func (v *StructuredLogView) SetLines(lines []string) {
+	v.mutex.Lock()
+	v.records = v.records[:0]
+	v.keys, v.keySeen = nil, make(map[string]bool)
+	v.mutex.Unlock()
+	v.list.UnselectAll()
+	v.detail.SetText("Select a line to show all its fields")
+	v.AppendLines(lines)
+}
+
+// AppendLines adds log lines at the end, dropping the oldest lines like the log view
+func (v *StructuredLogView) AppendLines(lines []string) {
+	records := make([]logRecord, len(lines))
+	for i, line := range lines {
+		records[i] = parseLogRecord(line)
+	}
+
+	v.mutex.Lock()
+	v.records = append(v.records, records...)
+	dropped := len(v.records) - logViewCapacity
+	if dropped > 0 {
+		v.records = append([]logRecord(nil), v.records[dropped:]...)
+	}
+	for _, record := range records {
+		for _, key := range record.keys {
+			if !v.keySeen[key] {
+				v.keySeen[key] = true
+				v.keys = append(v.keys, key)
+			}
+		}
+	}
+	if dropped > 0 {
+		v.shown = v.shown[:0]
+		v.filterRecords(0)
+	} else {
+		v.filterRecords(len(v.records) - len(records))
+	}
+	v.mutex.Unlock()
+
+	v.updateFieldOptions()
+	v.updateCount()
+	v.list.Refresh()
+}
+
+// add the records from index from on that match the filter, call with the mutex held
+func (v *StructuredLogView) filterRecords(from int) {
+	for i := from; i < len(v.records); i++ {
+		if matchesLogFilter(v.records[i], v.filter) {
+			v.shown = append(v.shown, i)
+		}
+	}
+}
+
+func (v *StructuredLogView) applyFilter() {
+	v.mutex.Lock()
+	v.shown = v.shown[:0]
+	v.filterRecords(0)
+	v.mutex.Unlock()
+	v.list.UnselectAll()
+	v.updateCount()
+	v.list.Refresh()
+	v.list.ScrollToTop()
+}
+
+func (v *StructuredLogView) updateCount() {
+	v.mutex.RLock()
+	shown, total := len(v.shown), len(v.records)
+	v.mutex.RUnlock()
+	if shown == total {
+		v.countLabel.SetText(strconv.Itoa(total) + " lines")
+		return
+	}
+	v.countLabel.SetText(strconv.Itoa(shown) + " of " + strconv.Itoa(total) + " lines")
+}
+
+// offer the field keys that aren't shown as columns
+func (v *StructuredLogView) updateFieldOptions() {
+	v.mutex.RLock()
+	shown := make(map[string]bool, len(v.columns))
+	for _, column := range v.columns {
+		shown[column] = true
+	}
+	options := make([]string, 0, len(v.keys))
+	for _, key := range v.keys {
+		if !shown[key] {
+			options = append(options, key)
+		}
+	}
+	v.mutex.RUnlock()
+	v.fieldSelect.Options = options
+	v.fieldSelect.Refresh()
+}
+
+// show the comma separated fields as columns after the message
+func (v *StructuredLogView) setColumns(text string) {
+	var columns []string
+	for _, column := range strings.Split(text, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+
+	v.mutex.Lock()
+	v.columns = columns
+	for len(v.widths) < len(structuredLogColumns)+len(columns) {
+		v.widths = append(v.widths, logFieldColumnWidth)
+	}
+	v.mutex.Unlock()
+
+	v.updateHeader()
+	v.updateFieldOptions()
+	v.list.Refresh()
+}
+
+func (v *StructuredLogView) updateHeader() {
+	v.mutex.RLock()
+	names := append(append([]string(nil), structuredLogColumns...), v.columns...)
+	v.mutex.RUnlock()
+
+	headers := make([]*columnHeader, len(names))
+	for i, name := range names {
+		column := i
+		headers[i] = newColumnHeader(name, nil, func(dx float32) { v.resizeColumn(column, dx) })
+	}
+	v.header.Objects = headerObjects(headers)
+	v.header.Refresh()
+}
+
+func (v *StructuredLogView) resizeColumn(column int, dx float32) {
+	v.widths[column] = fyne.Max(minColumnWidth, v.widths[column]+dx)
+	v.header.Refresh()
+	v.header.Layout.Layout(v.header.Objects, v.header.Size())
+	for _, row := range v.rows {
+		row.Layout.Layout(row.Objects, row.Size())
+	}
+	v.list.Refresh()
+	v.Content.Refresh()
+}
+
+func (v *StructuredLogView) updateRow(id widget.ListItemID, row *fyne.Container) {
+	v.mutex.RLock()
+	if id >= len(v.shown) {
+		v.mutex.RUnlock()
+		return
+	}
+	record := v.records[v.shown[id]]
+	values := []string{record.time, record.level, record.message}
+	for _, column := range v.columns {
+		values = append(values, record.fields[column])
+	}
+	v.mutex.RUnlock()
+
+	// rows may have been created before columns were added
+	addLogCells(row, len(values))
+	row.Objects = row.Objects[:len(values)]
+
+	color := getLogLevelColor(record.level)
+	for i, cell := range row.Objects {
+		richText := cell.(*widget.RichText)
+		segment := richText.Segments[0].(*widget.TextSegment)
+		// a table row shows the first line of multi-line values
+		segment.Text, _, _ = strings.Cut(values[i], "\n")
+		segment.Style.ColorName = color
+		richText.Refresh()
+	}
+	row.Layout.Layout(row.Objects, row.Size())
+}
+
+func addLogCells(row *fyne.Container, cells int) {
+	for len(row.Objects) < cells {
+		cell := widget.NewRichText(&widget.TextSegment{Style: widget.RichTextStyleInline})
+		cell.Wrapping = fyne.TextTruncate
+		row.Objects = append(row.Objects, cell)
+	}
+}
+
+// show the selected line as pretty-printed JSON
+func (v *StructuredLogView) showDetail(id widget.ListItemID) {
+	v.mutex.RLock()
+	if id >= len(v.shown) {
+		v.mutex.RUnlock()
+		return
+	}
+	record := v.records[v.shown[id]]
+	v.mutex.RUnlock()
+	v.detail.SetText(getPrettyLogRecord(record))
+}
+
+// isStructuredLog reports whether most of the last lines are JSON or logfmt
+func isStructuredLog(lines []string) bool {
+	if len(lines) > structuredLogSample {
+		lines = lines[len(lines)-structuredLogSample:]
+	}
+	structured := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if parseLogRecord(line).structured {
+			structured++
+		}
+	}
+	return structured > 0 && structured*2 >= len(lines)
+}
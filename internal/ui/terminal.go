@@ -0,0 +1,585 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/michaeljsaenz/kview/internal/k8s"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// shell choice that tries bash, ash and sh
+const terminalAutoShell = "Auto"
+
+// sequences sent for special keys, the cursor keys depend on the application cursor mode
+var terminalKeys = map[fyne.KeyName]string{
+	fyne.KeyReturn: "\r", fyne.KeyEnter: "\r", fyne.KeyBackspace: "\x7f", fyne.KeyTab: "\t", fyne.KeyEscape: "\x1b",
+	fyne.KeyInsert: "\x1b[2~", fyne.KeyDelete: "\x1b[3~", fyne.KeyPageUp: "\x1b[5~", fyne.KeyPageDown: "\x1b[6~",
+	fyne.KeyF1: "\x1bOP", fyne.KeyF2: "\x1bOQ", fyne.KeyF3: "\x1bOR", fyne.KeyF4: "\x1bOS",
+	fyne.KeyF5: "\x1b[15~", fyne.KeyF6: "\x1b[17~", fyne.KeyF7: "\x1b[18~", fyne.KeyF8: "\x1b[19~",
+	fyne.KeyF9: "\x1b[20~", fyne.KeyF10: "\x1b[21~", fyne.KeyF11: "\x1b[23~", fyne.KeyF12: "\x1b[24~",
+}
+
+var terminalCursorKeys = map[fyne.KeyName]byte{
+	fyne.KeyUp: 'A', fyne.KeyDown: 'B', fyne.KeyRight: 'C', fyne.KeyLeft: 'D', fyne.KeyHome: 'H', fyne.KeyEnd: 'F',
+}
+
+// control characters of keys pressed with Ctrl besides the letters
+var terminalControlKeys = map[fyne.KeyName]byte{
+	fyne.KeySpace: 0, fyne.KeyLeftBracket: 0x1b, fyne.KeyBackslash: 0x1c, fyne.KeyRightBracket: 0x1d,
+}
+
+// Terminal is a terminal emulator widget: it shows the output written to it and sends the keys
+// typed to OnInput. Ctrl+Shift+C and Ctrl+Shift+V copy the screen and paste, the mouse wheel
+// scrolls back through the lines scrolled off the screen.
+type Terminal struct {
+	widget.BaseWidget
+
+	// called with the bytes of typed keys and pasted text
+	OnInput func(data []byte)
+	// called when the size in characters changes
+	OnResize func(columns int, rows int)
+
+	mutex  sync.Mutex
+	screen *terminalScreen
+	// lines scrolled back from the bottom
+	scrollOffset int
+	focused      bool
+}
+
+func NewTerminal() *Terminal {
+	t := &Terminal{screen: newTerminalScreen(80, 24)}
+	t.screen.reply = t.input
+	t.ExtendBaseWidget(t)
+	return t
+}
+
+func (t *Terminal) CreateRenderer() fyne.WidgetRenderer {
+	return &terminalRenderer{terminal: t, cursor: canvas.NewRectangle(theme.PrimaryColor())}
+}
+
+// Write shows output of the program, it implements io.Writer
+func (t *Terminal) Write(data []byte) (int, error) {
+	t.mutex.Lock()
+	t.screen.write(data)
+	t.mutex.Unlock()
+	t.Refresh()
+	return len(data), nil
+}
+
+// Reset clears the screen and scrollback for a new session
+func (t *Terminal) Reset() {
+	t.mutex.Lock()
+	t.screen = newTerminalScreen(t.screen.columns, t.screen.rows)
+	t.screen.reply = t.input
+	t.scrollOffset = 0
+	t.mutex.Unlock()
+	t.Refresh()
+}
+
+// ScreenSize returns the size of the screen in characters
+func (t *Terminal) ScreenSize() (int, int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.screen.columns, t.screen.rows
+}
+
+// Text returns the scrollback and screen text
+func (t *Terminal) Text() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.screen.text()
+}
+
+// Paste sends text like typed, wrapped in bracketed paste markers when the program asked for them
+func (t *Terminal) Paste(text string) {
+	t.mutex.Lock()
+	bracketed := t.screen.bracketedPaste
+	t.mutex.Unlock()
+	if bracketed {
+		text = "\x1b[200~" + text + "\x1b[201~"
+	}
+	t.send([]byte(text))
+}
+
+// send typed input, scrolling back to the bottom
+func (t *Terminal) send(data []byte) {
+	t.mutex.Lock()
+	scrolled := t.scrollOffset != 0
+	t.scrollOffset = 0
+	t.mutex.Unlock()
+	if scrolled {
+		t.Refresh()
+	}
+	t.input(data)
+}
+
+func (t *Terminal) input(data []byte) {
+	if t.OnInput != nil {
+		t.OnInput(data)
+	}
+}
+
+func (t *Terminal) Tapped(*fyne.PointEvent) {
+	if c := fyne.CurrentApp().Driver().CanvasForObject(t); c != nil {
+		c.Focus(t)
+	}
+}
+
+func (t *Terminal) FocusGained() {
+	t.mutex.Lock()
+	t.focused = true
+	t.mutex.Unlock()
+	t.Refresh()
+}
+
+func (t *Terminal) FocusLost() {
+	t.mutex.Lock()
+	t.focused = false
+	t.mutex.Unlock()
+	t.Refresh()
+}
+
+// AcceptsTab keeps Tab in the terminal for shell completion
+func (t *Terminal) AcceptsTab() bool {
+	return true
+}
+
+func (t *Terminal) TypedRune(r rune) {
+	data := make([]byte, utf8.RuneLen(r))
+	utf8.EncodeRune(data, r)
+	t.send(data)
+}
+
+func (t *Terminal) TypedKey(e *fyne.KeyEvent) {
+	if sequence, ok := terminalKeys[e.Name]; ok {
+		t.send([]byte(sequence))
+		return
+	}
+	if final, ok := terminalCursorKeys[e.Name]; ok {
+		t.mutex.Lock()
+		application := t.screen.applicationCursor
+		t.mutex.Unlock()
+		if application {
+			t.send([]byte{0x1b, 'O', final})
+		} else {
+			t.send([]byte{0x1b, '[', final})
+		}
+	}
+}
+
+// Ctrl+key sends a control character, Alt+key sends ESC and the key
+func (t *Terminal) TypedShortcut(shortcut fyne.Shortcut) {
+	switch s := shortcut.(type) {
+	case *fyne.ShortcutCopy:
+		t.send([]byte{0x03})
+	case *fyne.ShortcutPaste:
+		t.send([]byte{0x16})
+	case *fyne.ShortcutCut:
+		t.send([]byte{0x18})
+	case *fyne.ShortcutSelectAll:
+		t.send([]byte{0x01})
+	case *desktop.CustomShortcut:
+		key := string(s.KeyName)
+		switch {
+		case s.Modifier == fyne.KeyModifierControl|fyne.KeyModifierShift && s.KeyName == fyne.KeyC:
+			if window := getWindowForObject(t); window != nil {
+				window.Clipboard().SetContent(t.Text())
+			}
+		case s.Modifier == fyne.KeyModifierControl|fyne.KeyModifierShift && s.KeyName == fyne.KeyV:
+			if window := getWindowForObject(t); window != nil {
+				t.Paste(window.Clipboard().Content())
+			}
+		case s.Modifier == fyne.KeyModifierControl && len(key) == 1 && key[0] >= 'A' && key[0] <= 'Z':
+			t.send([]byte{key[0] - 'A' + 1})
+		case s.Modifier == fyne.KeyModifierControl:
+			if control, ok := terminalControlKeys[s.KeyName]; ok {
+				t.send([]byte{control})
+			}
+		case s.Modifier == fyne.KeyModifierAlt && len(key) == 1:
+			t.send([]byte{0x1b, key[0] | 0x20})
+		}
+	}
+}
+
+// the mouse wheel scrolls back through the main screen's scrollback
+func (t *Terminal) Scrolled(e *fyne.ScrollEvent) {
+	_, lineHeight := getLogCellSize()
+	t.mutex.Lock()
+	if t.screen.alternate {
+		t.mutex.Unlock()
+		return
+	}
+	lines := int(e.Scrolled.DY / lineHeight)
+	if lines == 0 && e.Scrolled.DY != 0 {
+		lines = 1
+		if e.Scrolled.DY < 0 {
+			lines = -1
+		}
+	}
+	t.scrollOffset = clampInt(t.scrollOffset+lines, 0, len(t.screen.scrollback))
+	t.mutex.Unlock()
+	t.Refresh()
+}
+
+// resize the screen to the widget, call with the mutex held. Returns whether the size changed.
+func (t *Terminal) fitScreen(size fyne.Size) bool {
+	charWidth, lineHeight := getLogCellSize()
+	columns := int((size.Width - theme.Padding()*2) / charWidth)
+	rows := int(size.Height / lineHeight)
+	if columns < 1 || rows < 1 || (columns == t.screen.columns && rows == t.screen.rows) {
+		return false
+	}
+	t.screen.resize(columns, rows)
+	t.scrollOffset = clampInt(t.scrollOffset, 0, len(t.screen.scrollback))
+	return true
+}
+
+// one drawn line of the terminal: text backgrounds, text segments and underlines
+type terminalRow struct {
+	backgrounds []*canvas.Rectangle
+	texts       []*canvas.Text
+	underlines  []*canvas.Rectangle
+}
+
+type terminalRenderer struct {
+	terminal *Terminal
+	rows     []*terminalRow
+	cursor   *canvas.Rectangle
+	objects  []fyne.CanvasObject
+}
+
+func (r *terminalRenderer) Layout(size fyne.Size) {
+	t := r.terminal
+	t.mutex.Lock()
+	resized := t.fitScreen(size)
+	columns, rows := t.screen.columns, t.screen.rows
+	t.mutex.Unlock()
+	if resized && t.OnResize != nil {
+		t.OnResize(columns, rows)
+	}
+	r.update()
+}
+
+func (r *terminalRenderer) MinSize() fyne.Size {
+	charWidth, lineHeight := getLogCellSize()
+	return fyne.NewSize(charWidth*20+theme.Padding()*2, lineHeight*5)
+}
+
+func (r *terminalRenderer) Refresh() {
+	r.update()
+	canvas.Refresh(r.terminal)
+}
+
+func (r *terminalRenderer) Objects() []fyne.CanvasObject {
+	return r.objects
+}
+
+func (r *terminalRenderer) Destroy() {
+}
+
+// draw the visible lines, scrolled back lines come from the scrollback
+func (r *terminalRenderer) update() {
+	t := r.terminal
+	charWidth, lineHeight := getLogCellSize()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	screen := t.screen
+
+	objectsAdded := false
+	for len(r.rows) < screen.rows {
+		r.rows = append(r.rows, &terminalRow{})
+		objectsAdded = true
+	}
+
+	for i, row := range r.rows {
+		if i >= screen.rows {
+			hideRectangles(row.backgrounds)
+			hideTexts(row.texts)
+			hideRectangles(row.underlines)
+			continue
+		}
+		var line []terminalCell
+		if index := len(screen.scrollback) - t.scrollOffset + i; index < len(screen.scrollback) {
+			line = screen.scrollback[index]
+		} else {
+			line = screen.lines[i-t.scrollOffset]
+		}
+		y := float32(i) * lineHeight
+
+		// a text for every run of cells with the same style
+		backgrounds, texts, underlines := 0, 0, 0
+		for start := 0; start < len(line); {
+			end := start + 1
+			for end < len(line) && line[end].style == line[start].style {
+				end++
+			}
+			style := line[start].style
+			chars := make([]rune, end-start)
+			for j := range chars {
+				chars[j] = line[start+j].char
+			}
+			position := fyne.NewPos(theme.Padding()+float32(start)*charWidth, y)
+			size := fyne.NewSize(float32(end-start)*charWidth, lineHeight)
+			start = end
+			foreground, background := getANSIColors(style)
+
+			if background != nil {
+				rectangle := getPoolRectangle(&row.backgrounds, backgrounds, &objectsAdded)
+				backgrounds++
+				rectangle.FillColor = background
+				rectangle.Move(position)
+				rectangle.Resize(size)
+				rectangle.Show()
+				rectangle.Refresh()
+			}
+			text := string(chars)
+			if isBlank(text) && !style.Underline {
+				continue
+			}
+			segmentText := getPoolText(&row.texts, texts, &objectsAdded)
+			texts++
+			segmentText.Text = text
+			segmentText.Color = foreground
+			segmentText.TextStyle = fyne.TextStyle{Monospace: true, Bold: style.Bold, Italic: style.Italic}
+			segmentText.Move(position)
+			segmentText.Resize(size)
+			segmentText.Show()
+			segmentText.Refresh()
+
+			if style.Underline {
+				underline := getPoolRectangle(&row.underlines, underlines, &objectsAdded)
+				underlines++
+				underline.FillColor = foreground
+				underline.Move(fyne.NewPos(position.X, y+lineHeight-2))
+				underline.Resize(fyne.NewSize(size.Width, 1))
+				underline.Show()
+				underline.Refresh()
+			}
+		}
+		hideRectangles(row.backgrounds[backgrounds:])
+		hideTexts(row.texts[texts:])
+		hideRectangles(row.underlines[underlines:])
+	}
+
+	// a block cursor, dimmed without focus
+	cursorRow := screen.cursorY + t.scrollOffset
+	if screen.cursorVisible && cursorRow < screen.rows {
+		alpha := uint8(0x50)
+		if t.focused {
+			alpha = 0xa0
+		}
+		r.cursor.FillColor = withAlpha(theme.PrimaryColor(), alpha)
+		r.cursor.Move(fyne.NewPos(theme.Padding()+float32(screen.cursorX)*charWidth, float32(cursorRow)*lineHeight))
+		r.cursor.Resize(fyne.NewSize(charWidth, lineHeight))
+		r.cursor.Show()
+	} else {
+		r.cursor.Hide()
+	}
+	r.cursor.Refresh()
+
+	if objectsAdded || len(r.objects) == 0 {
+		r.objects = r.objects[:0]
+		for _, row := range r.rows {
+			for _, background := range row.backgrounds {
+				r.objects = append(r.objects, background)
+			}
+		}
+		r.objects = append(r.objects, r.cursor)
+		for _, row := range r.rows {
+			for _, text := range row.texts {
+				r.objects = append(r.objects, text)
+			}
+			for _, underline := range row.underlines {
+				r.objects = append(r.objects, underline)
+			}
+		}
+	}
+}
+
+func isBlank(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] != ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+// terminalInput passes typed input to a terminal session without blocking the UI
+type terminalInput struct {
+	data   chan []byte
+	done   chan struct{}
+	once   sync.Once
+	remain []byte
+}
+
+func newTerminalInput() *terminalInput {
+	return &terminalInput{data: make(chan []byte, 256), done: make(chan struct{})}
+}
+
+func (i *terminalInput) send(data []byte) {
+	select {
+	case <-i.done:
+	case i.data <- append([]byte(nil), data...):
+	}
+}
+
+func (i *terminalInput) Read(p []byte) (int, error) {
+	if len(i.remain) == 0 {
+		select {
+		case <-i.done:
+			return 0, io.EOF
+		case i.remain = <-i.data:
+		}
+	}
+	n := copy(p, i.remain)
+	i.remain = i.remain[n:]
+	return n, nil
+}
+
+func (i *terminalInput) Close() {
+	i.once.Do(func() { close(i.done) })
+}
+
+// TerminalSession is an interactive shell in a container shown in a Terminal, with a shell choice,
+// reconnect, copy and paste
+type TerminalSession struct {
+	Content fyne.CanvasObject
+
+	client        kubernetes.Interface
+	config        rest.Config
+	podNamespace  string
+	podName       string
+	containerName string
+	showError     func(err error)
+
+	terminal      *Terminal
+	shellSelect   *widget.Select
+	connectButton *widget.Button
+	statusLabel   *widget.Label
+
+	mutex  sync.Mutex
+	cancel context.CancelFunc
+	input  *terminalInput
+	sizes  *k8s.TerminalSizes
+}
+
+func NewTerminalSession(client kubernetes.Interface, config rest.Config, podNamespace string, podName string,
+	containerName string, showError func(err error)) *TerminalSession {
+	s := &TerminalSession{client: client, config: config, podNamespace: podNamespace, podName: podName,
+		containerName: containerName, showError: showError, cancel: func() {}}
+
+	s.terminal = NewTerminal()
+	s.terminal.OnInput = func(data []byte) {
+		s.mutex.Lock()
+		input := s.input
+		s.mutex.Unlock()
+		if input != nil {
+			input.send(data)
+		}
+	}
+	s.terminal.OnResize = func(columns int, rows int) {
+		s.mutex.Lock()
+		sizes := s.sizes
+		s.mutex.Unlock()
+		if sizes != nil {
+			sizes.Resize(uint16(columns), uint16(rows))
+		}
+	}
+
+	s.shellSelect = widget.NewSelect(append([]string{terminalAutoShell}, k8s.GetTerminalShells()...), nil)
+	s.shellSelect.Selected = terminalAutoShell
+	s.connectButton = widget.NewButtonWithIcon("Reconnect", theme.ViewRefreshIcon(), s.Start)
+	s.statusLabel = widget.NewLabel("")
+	copyButton := widget.NewButtonWithIcon("Copy", theme.ContentCopyIcon(), func() {
+		if window := getWindowForObject(s.terminal); window != nil {
+			window.Clipboard().SetContent(s.terminal.Text())
+		}
+	})
+	pasteButton := widget.NewButtonWithIcon("Paste", theme.ContentPasteIcon(), func() {
+		if window := getWindowForObject(s.terminal); window != nil {
+			s.terminal.Paste(window.Clipboard().Content())
+			window.Canvas().Focus(s.terminal)
+		}
+	})
+
+	toolbar := container.NewBorder(nil, nil,
+		container.NewHBox(widget.NewLabel("Shell"), s.shellSelect, s.connectButton, s.statusLabel), container.NewHBox(copyButton, pasteButton))
+	s.Content = container.NewBorder(toolbar, nil, nil, nil, s.terminal)
+	return s
+}
+
+// Start connects a new shell, ending the current one
+func (s *TerminalSession) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	input, sizes := newTerminalInput(), k8s.NewTerminalSizes()
+	s.mutex.Lock()
+	s.cancel()
+	if s.input != nil {
+		s.input.Close()
+	}
+	s.cancel, s.input, s.sizes = cancel, input, sizes
+	s.mutex.Unlock()
+
+	s.terminal.Reset()
+	if window := getWindowForObject(s.terminal); window != nil {
+		window.Canvas().Focus(s.terminal)
+	}
+	columns, rows := s.terminal.ScreenSize()
+	sizes.Resize(uint16(columns), uint16(rows))
+	shell := s.shellSelect.Selected
+
+	go func() {
+		defer input.Close()
+		if shell == terminalAutoShell {
+			s.statusLabel.SetText("Detecting shell...")
+			var err error
+			shell, err = k8s.DetectShell(ctx, s.client, s.config, s.podName, s.containerName, s.podNamespace)
+			if err != nil {
+				if ctx.Err() == nil {
+					s.statusLabel.SetText("No shell")
+					s.showError(err)
+				}
+				return
+			}
+		}
+
+		s.statusLabel.SetText("Connected: " + shell)
+		err := k8s.StartTerminal(ctx, s.client, s.config, s.podName, s.containerName, s.podNamespace, []string{shell},
+			input, s.terminal, sizes)
+		if ctx.Err() != nil {
+			return
+		}
+		if exitCode, ok := k8s.GetExitCode(err); ok {
+			s.statusLabel.SetText(fmt.Sprintf("Session ended (exit code %d)", exitCode))
+			return
+		}
+		if err != nil {
+			s.statusLabel.SetText("Session failed")
+			s.showError(err)
+			return
+		}
+		s.statusLabel.SetText("Session ended")
+	}()
+}
+
+// Close ends the shell
+func (s *TerminalSession) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cancel()
+	if s.input != nil {
+		s.input.Close()
+	}
+}
@@ -0,0 +1,564 @@
+package ui
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/michaeljsaenz/kview/internal/utils"
+)
+
+// lines scrolled off the main screen that are kept
+const terminalScrollback = 5000
+
+// terminalCell is a character on the terminal screen with its style
+type terminalCell struct {
+	char  rune
+	style utils.ANSIStyle
+}
+
+// states of the escape sequence parser
+const (
+	terminalGround = iota
+	terminalEscape
+	// ESC ( and friends select a character set, the next byte is ignored
+	terminalCharset
+	terminalCSI
+	// OSC and DCS strings end with BEL or ESC \
+	terminalString
+	terminalStringEscape
+)
+
+// terminalScreen emulates the screen of a VT100/xterm terminal: cursor movement, erasing, scroll
+// regions, SGR colors, the alternate screen used by full screen programs like vim and top, and
+// the modes they set. Lines scrolled off the main screen are kept as scrollback.
+type terminalScreen struct {
+	columns int
+	rows    int
+	lines   [][]terminalCell
+	// lines scrolled off the top of the main screen, oldest first
+	scrollback [][]terminalCell
+
+	cursorX, cursorY int
+	// the cursor is past the last column, the next character wraps
+	wrapPending bool
+	style       utils.ANSIStyle
+	savedX      int
+	savedY      int
+	savedStyle  utils.ANSIStyle
+	// scroll region, top and bottom rows
+	scrollTop    int
+	scrollBottom int
+
+	// the main screen while the alternate screen is shown
+	mainLines [][]terminalCell
+	alternate bool
+
+	cursorVisible bool
+	autowrap      bool
+	// application cursor keys (DECCKM) send ESC O A instead of ESC [ A
+	applicationCursor bool
+	bracketedPaste    bool
+	title             string
+
+	// parser state, a sequence or character can be split between writes
+	state      int
+	sequence   []byte
+	incomplete []byte
+
+	// answers to queries like the cursor position are sent to the program
+	reply func(data []byte)
+}
+
+func newTerminalScreen(columns int, rows int) *terminalScreen {
+	s := &terminalScreen{cursorVisible: true, autowrap: true}
+	s.resize(columns, rows)
+	return s
+}
+
+func newTerminalLine(columns int, style utils.ANSIStyle) []terminalCell {
+	line := make([]terminalCell, columns)
+	// erased cells keep the background color
+	blank := terminalCell{char: ' ', style: utils.ANSIStyle{Background: style.Background}}
+	for i := range line {
+		line[i] = blank
+	}
+	return line
+}
+
+// resize the screen, lines are cut or filled and lines above the screen go to the scrollback
+func (s *terminalScreen) resize(columns int, rows int) {
+	if columns < 1 {
+		columns = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	resizeLines := func(lines [][]terminalCell) [][]terminalCell {
+		for i, line := range lines {
+			if len(line) > columns {
+				lines[i] = line[:columns]
+			} else if len(line) < columns {
+				lines[i] = append(line, newTerminalLine(columns-len(line), utils.ANSIStyle{})...)
+			}
+		}
+		return lines
+	}
+
+	s.lines = resizeLines(s.lines)
+	// keep the cursor on the screen, the lines above it scroll off
+	for len(s.lines) > rows {
+		if s.cursorY > 0 {
+			s.pushScrollback(s.lines[0])
+			s.lines = s.lines[1:]
+			s.cursorY--
+		} else {
+			s.lines = s.lines[:len(s.lines)-1]
+		}
+	}
+	for len(s.lines) < rows {
+		s.lines = append(s.lines, newTerminalLine(columns, utils.ANSIStyle{}))
+	}
+	if s.mainLines != nil {
+		s.mainLines = resizeLines(s.mainLines)
+		for len(s.mainLines) > rows {
+			s.mainLines = s.mainLines[1:]
+		}
+		for len(s.mainLines) < rows {
+			s.mainLines = append(s.mainLines, newTerminalLine(columns, utils.ANSIStyle{}))
+		}
+	}
+
+	s.columns, s.rows = columns, rows
+	s.scrollTop, s.scrollBottom = 0, rows-1
+	s.cursorX, s.cursorY = clampInt(s.cursorX, 0, columns-1), clampInt(s.cursorY, 0, rows-1)
+	s.wrapPending = false
+}
+
+func (s *terminalScreen) pushScrollback(line []terminalCell) {
+	if s.alternate {
+		return
+	}
+	s.scrollback = append(s.scrollback, line)
+	if len(s.scrollback) > terminalScrollback {
+		s.scrollback = append([][]terminalCell(nil), s.scrollback[len(s.scrollback)-terminalScrollback:]...)
+	}
+}
+
+// write the output of the program to the screen
+func (s *terminalScreen) write(data []byte) {
+	if len(s.incomplete) > 0 {
+		data = append(s.incomplete, data...)
+		s.incomplete = nil
+	}
+	for i := 0; i < len(data); {
+		b := data[i]
+		if s.state != terminalGround || b < utf8.RuneSelf {
+			s.writeByte(b)
+			i++
+			continue
+		}
+		if !utf8.FullRune(data[i:]) {
+			s.incomplete = append([]byte(nil), data[i:]...)
+			return
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		s.print(r)
+		i += size
+	}
+}
+
+func (s *terminalScreen) writeByte(b byte) {
+	switch s.state {
+	case terminalEscape:
+		s.escape(b)
+		return
+	case terminalCharset:
+		s.state = terminalGround
+		return
+	case terminalCSI:
+		s.sequence = append(s.sequence, b)
+		if b >= 0x40 && b <= 0x7e {
+			s.state = terminalGround
+			s.csi(string(s.sequence))
+			s.sequence = s.sequence[:0]
+		}
+		return
+	case terminalString:
+		switch b {
+		case '\a':
+			s.endString()
+		case 0x1b:
+			s.state = terminalStringEscape
+		default:
+			s.sequence = append(s.sequence, b)
+		}
+		return
+	case terminalStringEscape:
+		// ESC \ ends the string, any other escape starts a new sequence
+		s.endString()
+		if b != '\\' {
+			s.state = terminalEscape
+			s.escape(b)
+		}
+		return
+	}
+
+	switch b {
+	case 0x1b:
+		s.state = terminalEscape
+	case '\r':
+		s.cursorX, s.wrapPending = 0, false
+	case '\n', '\v', '\f':
+		s.lineFeed()
+	case '\b':
+		if s.cursorX > 0 {
+			s.cursorX--
+		}
+		s.wrapPending = false
+	case '\t':
+		s.cursorX = clampInt((s.cursorX/8+1)*8, 0, s.columns-1)
+	case '\a', 0, 0x0e, 0x0f:
+		// bell, padding and character set shifts
+	default:
+		if b >= 0x20 && b != 0x7f {
+			s.print(rune(b))
+		}
+	}
+}
+
+func (s *terminalScreen) escape(b byte) {
+	s.state = terminalGround
+	switch b {
+	case '[':
+		s.state = terminalCSI
+		s.sequence = s.sequence[:0]
+	case ']', 'P', '_', '^':
+		s.state = terminalString
+		s.sequence = s.sequence[:0]
+	case '(', ')', '*', '+', '#', '%':
+		s.state = terminalCharset
+	case '7':
+		s.saveCursor()
+	case '8':
+		s.restoreCursor()
+	case 'D':
+		s.lineFeed()
+	case 'E':
+		s.cursorX = 0
+		s.lineFeed()
+	case 'M':
+		// reverse index, scrolls down at the top of the scroll region
+		if s.cursorY == s.scrollTop {
+			s.scrollDown(1)
+		} else if s.cursorY > 0 {
+			s.cursorY--
+		}
+		s.wrapPending = false
+	case 'c':
+		reply := s.reply
+		*s = *newTerminalScreen(s.columns, s.rows)
+		s.reply = reply
+	}
+}
+
+// an OSC string sets the window title, other strings are ignored
+func (s *terminalScreen) endString() {
+	s.state = terminalGround
+	text := string(s.sequence)
+	if code, title, found := strings.Cut(text, ";"); found && (code == "0" || code == "2") {
+		s.title = title
+	}
+	s.sequence = s.sequence[:0]
+}
+
+func (s *terminalScreen) print(r rune) {
+	if s.wrapPending && s.autowrap {
+		s.cursorX = 0
+		s.lineFeed()
+	}
+	s.wrapPending = false
+	s.lines[s.cursorY][s.cursorX] = terminalCell{char: r, style: s.style}
+	if s.cursorX < s.columns-1 {
+		s.cursorX++
+	} else {
+		s.wrapPending = true
+	}
+}
+
+// move down a line, scrolling up at the bottom of the scroll region
+func (s *terminalScreen) lineFeed() {
+	s.wrapPending = false
+	if s.cursorY == s.scrollBottom {
+		s.scrollUp(1)
+	} else if s.cursorY < s.rows-1 {
+		s.cursorY++
+	}
+}
+
+// scroll the scroll region up by n lines
+func (s *terminalScreen) scrollUp(n int) {
+	for i := 0; i < n; i++ {
+		if s.scrollTop == 0 {
+			s.pushScrollback(s.lines[0])
+		}
+		copy(s.lines[s.scrollTop:s.scrollBottom], s.lines[s.scrollTop+1:s.scrollBottom+1])
+		s.lines[s.scrollBottom] = newTerminalLine(s.columns, s.style)
+	}
+}
+
+// scroll the scroll region down by n lines
+func (s *terminalScreen) scrollDown(n int) {
+	for i := 0; i < n; i++ {
+		copy(s.lines[s.scrollTop+1:s.scrollBottom+1], s.lines[s.scrollTop:s.scrollBottom])
+		s.lines[s.scrollTop] = newTerminalLine(s.columns, s.style)
+	}
+}
+
+func (s *terminalScreen) saveCursor() {
+	s.savedX, s.savedY, s.savedStyle = s.cursorX, s.cursorY, s.style
+}
+
+func (s *terminalScreen) restoreCursor() {
+	s.cursorX, s.cursorY, s.style = clampInt(s.savedX, 0, s.columns-1), clampInt(s.savedY, 0, s.rows-1), s.savedStyle
+	s.wrapPending = false
+}
+
+// erase the cells from column from up to column to of a line
+func (s *terminalScreen) erase(y int, from int, to int) {
+	blank := newTerminalLine(1, s.style)[0]
+	for x := clampInt(from, 0, s.columns); x < clampInt(to, 0, s.columns); x++ {
+		s.lines[y][x] = blank
+	}
+}
+
+// handle a control sequence, the bytes after ESC [
+func (s *terminalScreen) csi(sequence string) {
+	final := sequence[len(sequence)-1]
+	parameters := sequence[:len(sequence)-1]
+	private := ""
+	if parameters != "" && strings.ContainsRune("?>=<", rune(parameters[0])) {
+		private, parameters = parameters[:1], parameters[1:]
+	}
+	var values []int
+	if parameters != "" {
+		for _, field := range strings.Split(parameters, ";") {
+			value, _ := strconv.Atoi(field)
+			values = append(values, value)
+		}
+	}
+	// the n-th parameter, missing and 0 parameters are the default
+	parameter := func(n int, defaultValue int) int {
+		if n < len(values) && values[n] > 0 {
+			return values[n]
+		}
+		return defaultValue
+	}
+
+	if final != 'm' {
+		s.wrapPending = false
+	}
+	switch final {
+	case 'A':
+		s.cursorY = clampInt(s.cursorY-parameter(0, 1), s.topLimit(), s.rows-1)
+	case 'B', 'e':
+		s.cursorY = clampInt(s.cursorY+parameter(0, 1), 0, s.bottomLimit())
+	case 'C', 'a':
+		s.cursorX = clampInt(s.cursorX+parameter(0, 1), 0, s.columns-1)
+	case 'D':
+		s.cursorX = clampInt(s.cursorX-parameter(0, 1), 0, s.columns-1)
+	case 'E':
+		s.cursorX, s.cursorY = 0, clampInt(s.cursorY+parameter(0, 1), 0, s.bottomLimit())
+	case 'F':
+		s.cursorX, s.cursorY = 0, clampInt(s.cursorY-parameter(0, 1), s.topLimit(), s.rows-1)
+	case 'G', '`':
+		s.cursorX = clampInt(parameter(0, 1)-1, 0, s.columns-1)
+	case 'd':
+		s.cursorY = clampInt(parameter(0, 1)-1, 0, s.rows-1)
+	case 'H', 'f':
+		s.cursorY, s.cursorX = clampInt(parameter(0, 1)-1, 0, s.rows-1), clampInt(parameter(1, 1)-1, 0, s.columns-1)
+	case 'J':
+		s.eraseDisplay(parameter(0, 0))
+	case 'K':
+		switch parameter(0, 0) {
+		case 0:
+			s.erase(s.cursorY, s.cursorX, s.columns)
+		case 1:
+			s.erase(s.cursorY, 0, s.cursorX+1)
+		case 2:
+			s.erase(s.cursorY, 0, s.columns)
+		}
+	case 'L', 'M':
+		// insert or delete lines within the scroll region
+		if s.cursorY < s.scrollTop || s.cursorY > s.scrollBottom {
+			return
+		}
+		top := s.scrollTop
+		s.scrollTop = s.cursorY
+		if final == 'L' {
+			s.scrollDown(clampInt(parameter(0, 1), 1, s.rows))
+		} else {
+			// deleted lines don't go to the scrollback
+			bottom := s.scrollBottom
+			for i := 0; i < clampInt(parameter(0, 1), 1, s.rows); i++ {
+				copy(s.lines[s.scrollTop:bottom], s.lines[s.scrollTop+1:bottom+1])
+				s.lines[bottom] = newTerminalLine(s.columns, s.style)
+			}
+		}
+		s.scrollTop, s.cursorX = top, 0
+	case '@':
+		n := clampInt(parameter(0, 1), 1, s.columns-s.cursorX)
+		line := s.lines[s.cursorY]
+		copy(line[s.cursorX+n:], line[s.cursorX:])
+		s.erase(s.cursorY, s.cursorX, s.cursorX+n)
+	case 'P':
+		n := clampInt(parameter(0, 1), 1, s.columns-s.cursorX)
+		line := s.lines[s.cursorY]
+		copy(line[s.cursorX:], line[s.cursorX+n:])
+		s.erase(s.cursorY, s.columns-n, s.columns)
+	case 'X':
+		s.erase(s.cursorY, s.cursorX, s.cursorX+parameter(0, 1))
+	case 'S':
+		if private == "" {
+			s.scrollUp(clampInt(parameter(0, 1), 1, s.rows))
+		}
+	case 'T':
+		if private == "" {
+			s.scrollDown(clampInt(parameter(0, 1), 1, s.rows))
+		}
+	case 'r':
+		top, bottom := parameter(0, 1)-1, parameter(1, s.rows)-1
+		if top < bottom && bottom < s.rows {
+			s.scrollTop, s.scrollBottom = top, bottom
+			s.cursorX, s.cursorY = 0, 0
+		}
+	case 's':
+		s.saveCursor()
+	case 'u':
+		s.restoreCursor()
+	case 'm':
+		if private == "" {
+			s.style = utils.ApplySGR(s.style, parameters)
+		}
+	case 'h', 'l':
+		if private == "?" {
+			for _, mode := range values {
+				s.setMode(mode, final == 'h')
+			}
+		}
+	case 'n':
+		// device status report: the cursor position
+		if parameter(0, 0) == 6 {
+			s.sendReply("\x1b[" + strconv.Itoa(s.cursorY+1) + ";" + strconv.Itoa(s.cursorX+1) + "R")
+		} else if parameter(0, 0) == 5 {
+			s.sendReply("\x1b[0n")
+		}
+	case 'c':
+		// device attributes: a VT100 with advanced video
+		if private == "" {
+			s.sendReply("\x1b[?1;2c")
+		}
+	}
+}
+
+// cursor movement stops at the scroll region when the cursor is in it
+func (s *terminalScreen) topLimit() int {
+	if s.cursorY >= s.scrollTop {
+		return s.scrollTop
+	}
+	return 0
+}
+
+func (s *terminalScreen) bottomLimit() int {
+	if s.cursorY <= s.scrollBottom {
+		return s.scrollBottom
+	}
+	return s.rows - 1
+}
+
+func (s *terminalScreen) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		s.erase(s.cursorY, s.cursorX, s.columns)
+		for y := s.cursorY + 1; y < s.rows; y++ {
+			s.erase(y, 0, s.columns)
+		}
+	case 1:
+		for y := 0; y < s.cursorY; y++ {
+			s.erase(y, 0, s.columns)
+		}
+		s.erase(s.cursorY, 0, s.cursorX+1)
+	case 2:
+		for y := 0; y < s.rows; y++ {
+			s.erase(y, 0, s.columns)
+		}
+	case 3:
+		s.scrollback = nil
+	}
+}
+
+func (s *terminalScreen) setMode(mode int, set bool) {
+	switch mode {
+	case 1:
+		s.applicationCursor = set
+	case 7:
+		s.autowrap = set
+	case 25:
+		s.cursorVisible = set
+	case 2004:
+		s.bracketedPaste = set
+	case 47, 1047, 1049:
+		if set == s.alternate {
+			return
+		}
+		if mode == 1049 && set {
+			s.saveCursor()
+		}
+		if set {
+			s.mainLines = s.lines
+			s.lines = make([][]terminalCell, s.rows)
+			for i := range s.lines {
+				s.lines[i] = newTerminalLine(s.columns, utils.ANSIStyle{})
+			}
+		} else {
+			s.lines, s.mainLines = s.mainLines, nil
+		}
+		s.alternate = set
+		if mode == 1049 && !set {
+			s.restoreCursor()
+		}
+	}
+}
+
+func (s *terminalScreen) sendReply(reply string) {
+	if s.reply != nil {
+		s.reply([]byte(reply))
+	}
+}
+
+// text of the screen lines without trailing spaces, for copying and tests
+func (s *terminalScreen) text() string {
+	lines := make([]string, 0, len(s.scrollback)+len(s.lines))
+	for _, line := range append(append([][]terminalCell(nil), s.scrollback...), s.lines...) {
+		lines = append(lines, getTerminalLineText(line))
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+func getTerminalLineText(line []terminalCell) string {
+	var text strings.Builder
+	for _, cell := range line {
+		text.WriteRune(cell.char)
+	}
+	return strings.TrimRight(text.String(), " ")
+}
+
+func clampInt(value int, low int, high int) int {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
@@ -1,153 +1,129 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"image/color"
 	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/michaeljsaenz/kview/internal/k8s"
-	"github.com/michaeljsaenz/kview/internal/utils"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
 
-func GetListData(podData *[]string) (binding.ExternalStringList, *widget.List) {
-	// list binding, bind pod list data to data
-	data := binding.BindStringList(
-		podData,
-	)
-
-	list := widget.NewListWithData(data,
-		func() fyne.CanvasObject {
-			return widget.NewLabel("template")
-		},
-		func(i binding.DataItem, o fyne.CanvasObject) {
-			o.(*widget.Label).Bind(i.(binding.String))
-		})
-
-	return data, list
-}
-
-func SetupErrorUI(stringErrorResponse string, namespaceListDropdown *widget.Select,
-	input *widget.Entry, app fyne.App) {
+func SetupErrorUI(action string, err error, namespaceListDropdown *widget.Select,
+	input *widget.Entry, statusBar *StatusBar, retry func()) {
 
-	// disable main window content
+	// disable main window content until the cluster can be reached
 	namespaceListDropdown.Disable()
 	input.Disable()
 
-	// error window
-	errorLabel := widget.NewLabel("")
-	errorLabel.TextStyle = fyne.TextStyle{Monospace: true}
-	errorLabel.Text = stringErrorResponse
-	errorLabel.Wrapping = fyne.TextWrapBreak
-	errorLabel.TextStyle = fyne.TextStyle{Italic: true, Bold: true}
-
-	// display error message
-	errorContainer := container.NewHScroll(errorLabel)
-	errorWin := app.NewWindow("Error")
-	bottomBox := container.NewVBox(
-		widget.NewButtonWithIcon("Close", theme.ErrorIcon(), func() {
-			errorWin.Close()
-		}),
-	)
-
-	content := container.NewBorder(nil, bottomBox, nil, nil, errorContainer)
-	errorWin.Resize(fyne.NewSize(500, 100))
-	errorWin.SetContent(content)
-	errorWin.CenterOnScreen()
-	errorWin.SetMaster()
-	errorWin.RequestFocus()
-	errorWin.Show()
+	// display error message with the remediation hint of its class
+	statusBar.setError(action, err, retry, true)
 }
 
-func ListOnSelected(list *widget.List, data binding.ExternalStringList, clientset kubernetes.Clientset, config rest.Config, title, podStatus,
-	podLabels, podAnnotations, podEvents, podVolumes, podLog *widget.Label, podDetailLog *widget.Label, podTabs *container.AppTabs, podLogTabs *container.AppTabs,
-	podLogScroll *container.Scroll, podLogsLabel *widget.Label, app fyne.App, yb *widget.Button, execButtons []*widget.Button, namespaceListDropdown *widget.Select) {
+func ListOnSelected(list *widget.List, data binding.ExternalStringList, clientset kubernetes.Clientset, config rest.Config, podCache *k8s.PodCache,
+	loader *Loader, title, podStatus, podLabels, podAnnotations, podEvents, podVolumes, podLog *widget.Label, podDetailLog *widget.Label, podTabs *container.AppTabs,
+	podLogTabs *container.AppTabs, podLogScroll *container.Scroll, podLogsLabel *widget.Label, app fyne.App, yb *widget.Button, eb *widget.Button, execButtons []*widget.Button,
+	containers *ContainerControls, namespaceListDropdown *widget.Select, showError func(action string, err error, retry func())) {
 	list.OnSelected = func(id widget.ListItemID) {
 
 		selectedPod, err := data.GetValue(id)
 		if err != nil {
-			panic(err.Error())
+			showError("Select application (pod)", err, nil)
+			return
 		}
 		title.Text = "Application (Pod): " + selectedPod
 		title.Refresh()
 
-		newPodStatus, newPodAge, newNodeName, newContainers := k8s.GetPodDetail(clientset, selectedPod, namespaceListDropdown.Selected)
+		// the new selection cancels the API calls of the previous one
+		ctx := loader.Reset()
 
+		// fetch the pod once per selection, tabs and YAML are derived from the cached pod
 		newPodNamespace := namespaceListDropdown.Selected
-		podStatus.Text = "Status: " + newPodStatus + "\n" +
-			"Age: " + newPodAge + "\n" +
-			"Namespace: " + newPodNamespace + "\n" +
-			"Node: " + newNodeName
-		podStatus.Refresh()
-
-		podTabs.OnSelected = func(tabItemName *container.TabItem) {
-			selectedTabItemName := tabItemName.Text
-			switch selectedTabItemName {
+		podCache.Invalidate(newPodNamespace, selectedPod)
+		getPod := func(ctx context.Context) (*corev1.Pod, error) {
+			return podCache.GetPod(ctx, &clientset, newPodNamespace, selectedPod)
+		}
+
+		// show a loading pane, then the loaded text
+		loadPane := func(pane *widget.Label, action string, retry func(), load func(ctx context.Context) (string, error)) {
+			pane.Text = "Loading..."
+			pane.Refresh()
+			loader.Run(ctx, func(ctx context.Context) func() {
+				text, err := load(ctx)
+				return func() {
+					if err != nil {
+						showError(action, err, retry)
+					}
+					pane.Text = text
+					pane.Refresh()
+				}
+			})
+		}
+
+		// load the content of the selected pod tab
+		var loadPodTab func(tabItemName string)
+		loadPodTab = func(tabItemName string) {
+			retry := func() { loadPodTab(tabItemName) }
+			switch tabItemName {
 			case "Labels":
 				// get pod labels
-				newPodLabels := k8s.GetPodLabels(clientset, selectedPod, namespaceListDropdown.Selected)
-				podLabels.Text = newPodLabels
-				podLabels.Refresh()
+				loadPane(podLabels, "Get pod labels", retry, func(ctx context.Context) (string, error) {
+					pod, err := getPod(ctx)
+					if err != nil {
+						return "", err
+					}
+					return k8s.GetPodLabels(pod), nil
+				})
 			case "Annotations":
 				// get pod annotations
-				newPodAnnotations := k8s.GetPodAnnotations(clientset, selectedPod, namespaceListDropdown.Selected)
-				podAnnotations.Text = newPodAnnotations
-				podAnnotations.Refresh()
+				loadPane(podAnnotations, "Get pod annotations", retry, func(ctx context.Context) (string, error) {
+					pod, err := getPod(ctx)
+					if err != nil {
+						return "", err
+					}
+					return k8s.GetPodAnnotations(pod), nil
+				})
 			case "Events":
 				// get pod events
-				newPodEvents := k8s.GetPodEvents(clientset, selectedPod, namespaceListDropdown.Selected)
-				strNewPodEvents := strings.Join(newPodEvents, "\n")
-				podEvents.Text = strNewPodEvents
-				podEvents.Refresh()
+				loadPane(podEvents, "Get pod events", retry, func(ctx context.Context) (string, error) {
+					newPodEvents, err := k8s.GetPodEvents(ctx, clientset, selectedPod, newPodNamespace)
+					return strings.Join(newPodEvents, "\n"), err
+				})
 			case "Volumes":
 				// get pod volumes
-				newVolumes, err := k8s.GetPodVolumes(clientset, selectedPod, namespaceListDropdown.Selected)
-				if err != nil {
-					fmt.Printf("error with GetPodVolumes: %v\n", err)
-				}
-				podVolumes.Text = newVolumes
-				podVolumes.Refresh()
+				loadPane(podVolumes, "Get pod volumes", retry, func(ctx context.Context) (string, error) {
+					pod, err := getPod(ctx)
+					if err != nil {
+						return "", err
+					}
+					return k8s.GetPodVolumes(pod)
+				})
 			}
-
 		}
 
-		currentSelectedTabItemName := podTabs.Selected()
-		switch currentSelectedTabItemName.Text {
-		case "Labels":
-			// get pod labels
-			newPodLabels := k8s.GetPodLabels(clientset, selectedPod, namespaceListDropdown.Selected)
-			podLabels.Text = newPodLabels
-			podLabels.Refresh()
-		case "Annotations":
-			// get pod annotations
-			newPodAnnotations := k8s.GetPodAnnotations(clientset, selectedPod, namespaceListDropdown.Selected)
-			podAnnotations.Text = newPodAnnotations
-			podAnnotations.Refresh()
-		case "Events":
-			// get pod events
-			newPodEvents := k8s.GetPodEvents(clientset, selectedPod, namespaceListDropdown.Selected)
-			strNewPodEvents := strings.Join(newPodEvents, "\n")
-			podEvents.Text = strNewPodEvents
-			podEvents.Refresh()
-		case "Volumes":
-			// get pod volumes
-			newVolumes, err := k8s.GetPodVolumes(clientset, selectedPod, namespaceListDropdown.Selected)
-			if err != nil {
-				fmt.Printf("error with GetPodVolumes: %v\n", err)
-			}
-			podVolumes.Text = newVolumes
-			podVolumes.Refresh()
+		// clear the previous pod while the selected one loads
+		SetPodStatus(podStatus, "Loading...", "", newPodNamespace, "")
+		podTabs.OnSelected = nil
+		for _, pane := range []*widget.Label{podLabels, podAnnotations, podEvents, podVolumes} {
+			pane.Text = ""
+			pane.Refresh()
 		}
-
-		yb.Show()
+		yb.Hide()
+		eb.Hide()
+		containers.Clear()
 
 		// remove container log tabs before loading current selection
 		podLogTabItems := len(podLogTabs.Items)
@@ -160,130 +136,240 @@ func ListOnSelected(list *widget.List, data binding.ExternalStringList, clientse
 			podLogTabItems = len(podLogTabs.Items)
 		}
 
-		for _, tabContainerName := range newContainers {
-			podLogScroll.SetMinSize(fyne.Size{Height: 200})
-			podLogTabs.Append(container.NewTabItemWithIcon(tabContainerName, theme.DocumentIcon(), podLogScroll))
-			podLogTabs.Refresh()
-		}
-
-		for i, buttonContainerName := range newContainers {
-			execButtons[i].SetText(buttonContainerName)
-			execButtons[i].Show()
-			if i == 9 {
-				break
-			}
-		}
-
-		// assign the OnTapped function to each button
-		for _, button := range execButtons {
-			button := button
-			button.OnTapped = func() {
-
-				win := app.NewWindow("Container Name: " + button.Text)
+		loader.Run(ctx, func(ctx context.Context) func() {
+			pod, err := getPod(ctx)
+			return func() {
+				if err != nil {
+					SetPodStatus(podStatus, "", "", newPodNamespace, "")
+					showError("Get pod "+selectedPod, err, func() { list.OnSelected(id) })
+					return
+				}
 
-				// Create an entry field for user input
-				entry := widget.NewEntry()
-				entry.SetPlaceHolder("Enter a command")
+				newPodStatus, newPodAge, newNodeName, newContainers := k8s.GetPodDetail(pod)
+				SetPodStatus(podStatus, newPodStatus, newPodAge, newPodNamespace, newNodeName)
 
-				// Create a label for displaying the prompt and command output
-				outputLabel := widget.NewLabel("")
-				podOutputScroll := container.NewScroll(outputLabel)
+				podTabs.OnSelected = func(tabItemName *container.TabItem) {
+					loadPodTab(tabItemName.Text)
+				}
+				loadPodTab(podTabs.Selected().Text)
 
-				// OnSubmitted event handler to execute the command
-				entry.OnSubmitted = func(command string) {
-					client := k8s.GetClientInterface(clientset)
-					// execute the command and return string output
-					commandOutput, err := k8s.ExecCmd(client, config, selectedPod, button.Text, namespaceListDropdown.Selected, command, nil)
-					if err != nil {
-						fmt.Println(err)
-					}
+				yb.Show()
+				eb.Show()
 
-					// update the output label
-					commandOutput = utils.RemoveANSIEscapeCodes(commandOutput)
-					outputLabel.SetText(commandOutput)
+				// log tabs and exec buttons of all containers, init and ephemeral containers included
+				podLogScroll.SetMinSize(fyne.Size{Height: 200})
+				containers.Show(newContainers)
+			}
+		})
 
-					// Use the captured stdout content as needed
-					podOutputScroll = container.NewScroll(outputLabel)
-					entry.SetText("") // clear the input field
+		// assign the OnTapped function to each button
+		for i, button := range execButtons {
+			i := i
+			button.OnTapped = func() {
+				podContainer, ok := containers.ExecContainer(i)
+				if !ok {
+					return
 				}
-
-				bottomBox := container.NewVBox(
-					widget.NewButtonWithIcon("Copy Output", theme.ContentCopyIcon(), func() {
-						win.Clipboard().SetContent(outputLabel.Text)
-					}),
+				containerName := podContainer.Name
+
+				win := app.NewWindow("Container Name: " + containerName)
+
+				// an interactive shell, one-shot commands stay available in the quick command tab
+				terminalSession := NewTerminalSession(k8s.GetClientInterface(clientset), config, newPodNamespace, selectedPod,
+					containerName, func(err error) { dialog.ShowError(err, win) })
+
+				// one-shot commands streaming their output
+				execCommand := NewExecCommand(k8s.GetClientInterface(clientset), config, newPodNamespace, selectedPod,
+					containerName, func(err error) { dialog.ShowError(err, win) })
+
+				// files copied from and to the container with tar
+				fileCopy := NewFileCopy(k8s.GetClientInterface(clientset), config, newPodNamespace, selectedPod,
+					containerName, func(err error) { dialog.ShowError(err, win) })
+
+				// the files of the container, the selected one is the path of the copy bar
+				fileBrowser := NewFileBrowser(k8s.GetClientInterface(clientset), config, newPodNamespace, selectedPod,
+					containerName, func(err error) { dialog.ShowError(err, win) })
+				fileBrowser.OnSelected = fileCopy.SetPath
+
+				// the running command, copy, file browser and shell are ended when the window closes
+				win.SetOnClosed(func() {
+					execCommand.Stop()
+					fileCopy.Cancel()
+					fileBrowser.Close()
+					terminalSession.Close()
+				})
+
+				content := container.NewAppTabs(
+					container.NewTabItemWithIcon("Terminal", theme.ComputerIcon(), terminalSession.Content),
+					container.NewTabItemWithIcon("Quick Command", theme.MediaPlayIcon(), execCommand.Content),
+					container.NewTabItemWithIcon("Files", theme.FolderIcon(),
+						container.NewBorder(fileCopy.Content, nil, nil, nil, fileBrowser.Content)),
 				)
 
-				content := container.NewBorder(entry, bottomBox, nil, nil, podOutputScroll)
-
 				win.SetContent(content)
 				win.Resize(fyne.NewSize(1200, 700))
 				win.Show()
+				terminalSession.Start()
 			}
 		}
 
+		// the log tab of the selected container, following stops when another tab is selected
+		var logTab *LogTab
 		podLogTabs.OnSelected = func(containerTabItemName *container.TabItem) {
-			var containerLogStream string
-			if podLogsLabel.Text != podLogTabs.Selected().Text {
-				containerLogStream = k8s.GetPodLogs(clientset, newPodNamespace, selectedPod, containerTabItemName.Text)
+			if logTab != nil {
+				logTab.Close()
+				logTab = nil
+			}
+			if podLogsLabel.Text == containerTabItemName.Text {
+				podLog = widget.NewLabel("")
+				podLogScroll = container.NewScroll(podLog)
+				podLogScroll.SetMinSize(fyne.Size{Height: 200})
+				containerTabItemName.Content = podLogScroll
+				podLogTabs.Refresh()
+				return
+			}
+			podContainer, ok := containers.LogTabContainer(containerTabItemName)
+			if !ok {
+				return
 			}
-			podLog = widget.NewLabel(containerLogStream)
-			podLogScroll = container.NewScroll(podLog)
-			podLogScroll.SetMinSize(fyne.Size{Height: 200})
-			podLogTabs.Selected().Content = podLogScroll
-			podLogScroll.Refresh()
+
+			logTab = NewLogTab(ctx, loader, clientset, getPod, newPodNamespace, selectedPod, podContainer.Name, app.Preferences(), showError)
+			containerTabItemName.Content = logTab.Content
 			podLogTabs.Refresh()
+			logTab.Load()
+		}
+
+		eb.OnTapped = func() {
+			win := getWindowForObject(eb)
+			if win == nil {
+				return
+			}
+			saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+				if err != nil {
+					showError("Export logs of pod "+selectedPod, err, nil)
+					return
+				}
+				// canceled
+				if writer == nil {
+					return
+				}
+				eb.Disable()
+				// the export goes on when another pod is selected
+				go func() {
+					defer eb.Enable()
+					manifest, err := exportPodLogs(context.Background(), clientset, getPod, writer)
+					if err != nil {
+						// don't leave a broken bundle behind
+						storage.Delete(writer.URI())
+						showError("Export logs of pod "+selectedPod, err, eb.OnTapped)
+						return
+					}
+					dialog.ShowInformation("Export All Logs", getLogExportSummary(manifest, writer.URI().Name()), win)
+				}()
+			}, win)
+			saveDialog.SetFileName(k8s.GetLogBundleName(selectedPod, time.Now()))
+			saveDialog.Show()
 		}
 
 		yb.OnTapped = func() {
 			// export yaml and display in new window
-			win := app.NewWindow("Application (Pod): " + selectedPod)
-			podYaml, err := k8s.GetPodYaml(clientset, newPodNamespace, selectedPod)
-			if err != nil {
-				fmt.Printf("error with GetPodYaml: %v\n", err)
-			}
-			podYamlScroll := container.NewScroll(widget.NewLabel(podYaml))
-
-			bottomBox := container.NewVBox(
-				widget.NewButtonWithIcon("Copy YAML", theme.ContentCopyIcon(), func() {
-					win.Clipboard().SetContent(podYaml)
-				}),
-			)
-			content := container.NewBorder(nil, bottomBox, nil, nil, podYamlScroll)
-
-			win.SetContent(content)
-			win.Resize(fyne.NewSize(1200, 700))
-			win.Show()
+			loader.Run(ctx, func(ctx context.Context) func() {
+				pod, err := getPod(ctx)
+				var podYaml string
+				if err == nil {
+					podYaml, err = k8s.GetPodYaml(pod)
+				}
+				return func() {
+					if err != nil {
+						showError("Get pod YAML", err, yb.OnTapped)
+						return
+					}
+					win := app.NewWindow("Application (Pod): " + selectedPod)
+					podYamlScroll := container.NewScroll(widget.NewLabel(podYaml))
+
+					bottomBox := container.NewVBox(
+						widget.NewButtonWithIcon("Copy YAML", theme.ContentCopyIcon(), func() {
+							win.Clipboard().SetContent(podYaml)
+						}),
+					)
+					content := container.NewBorder(nil, bottomBox, nil, nil, podYamlScroll)
+
+					win.SetContent(content)
+					win.Resize(fyne.NewSize(1200, 700))
+					win.Show()
+				}
+			})
 		}
 
 	}
 }
 
-func InputOnSubmitted(input *widget.Entry, clientset kubernetes.Clientset, namespaceListDropdown *widget.Select) []string {
-	// submit to func input string (pod name), return new pod list
+// write the log bundle of a pod and close the writer
+func exportPodLogs(ctx context.Context, clientset kubernetes.Clientset, getPod func(ctx context.Context) (*corev1.Pod, error),
+	writer fyne.URIWriteCloser) (k8s.LogManifest, error) {
+	pod, err := getPod(ctx)
+	if err != nil {
+		writer.Close()
+		return k8s.LogManifest{}, err
+	}
+	manifest, err := k8s.ExportPodLogs(ctx, &clientset, pod, writer, time.Now())
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	return manifest, err
+}
+
+// describe an exported log bundle, logs that couldn't be read are listed with their error
+func getLogExportSummary(manifest k8s.LogManifest, fileName string) string {
+	exported := 0
+	var failed []string
+	for _, file := range manifest.Files {
+		if file.Name != "" {
+			exported++
+		}
+		if file.Error != "" {
+			log := file.Container
+			if file.Previous {
+				log += " (previous)"
+			}
+			failed = append(failed, log+": "+file.Error)
+		}
+	}
+	summary := fmt.Sprintf("Exported %d logs of pod %s to %s", exported, manifest.Pod, fileName)
+	if len(failed) > 0 {
+		summary += "\n\nNot complete:\n" + strings.Join(failed, "\n")
+	}
+	return summary
+}
+
+func InputOnSubmitted(input *widget.Entry, podNames []string) []string {
+	// filter pod names with input string (pod name), empty input keeps all pods
 	inputText := input.Text
-	var inputTextList, podData []string
 	if inputText == "" {
-		return podData
+		return podNames
 	}
-	if namespaceListDropdown.Selected != "" {
-		podData = k8s.GetPodDataWithNamespace(clientset, namespaceListDropdown.Selected)
-		for _, pod := range podData {
-			if strings.Contains(pod, inputText) {
-				inputTextList = append(inputTextList, pod)
-			}
+	var inputTextList []string
+	for _, pod := range podNames {
+		if strings.Contains(pod, inputText) {
+			inputTextList = append(inputTextList, pod)
 		}
-		podData = inputTextList
-		return podData
-	} else {
-		return podData
 	}
+	return inputTextList
 }
 
-func CreateWindows(currentContext string) (*canvas.Text, *fyne.Container, *fyne.Container, *widget.Label) {
-	// top window label
+func SetPodStatus(podStatus *widget.Label, status, age, namespace, node string) {
+	podStatus.Text = "Status: " + status + "\n" +
+		"Age: " + age + "\n" +
+		"Namespace: " + namespace + "\n" +
+		"Node: " + node
+	podStatus.Refresh()
+}
+
+func CreateWindows(currentContext string, contextControls ...fyne.CanvasObject) (*canvas.Text, *fyne.Container, *fyne.Container, *widget.Label) {
+	// top window label, context controls next to it
 	topWindowLabel := canvas.NewText(("Cluster Context: " + currentContext), color.NRGBA{R: 57, G: 112, B: 228, A: 255})
 	topWindowLabel.TextStyle = fyne.TextStyle{Monospace: true}
-	topWindow := container.New(layout.NewCenterLayout(), topWindowLabel)
+	topWindow := container.New(layout.NewCenterLayout(), container.NewHBox(append([]fyne.CanvasObject{topWindowLabel}, contextControls...)...))
 
 	// right side of split
 	rightWindow := container.NewMax()
@@ -402,3 +488,29 @@ func UpdateInput(input *widget.Entry, data binding.ExternalStringList, list *wid
 	data.Reload()
 	list.UnselectAll()
 }
+
+func CreateBanner() (*fyne.Container, *widget.Label) {
+	// notice bar, hidden until there is something to announce
+	bannerLabel := widget.NewLabel("")
+	bannerLabel.TextStyle = fyne.TextStyle{Monospace: true, Bold: true}
+	bannerLabel.Wrapping = fyne.TextWrapWord
+
+	var banner *fyne.Container
+	closeButton := widget.NewButtonWithIcon("", theme.CancelIcon(), func() {
+		banner.Hide()
+	})
+	closeButton.Importance = widget.LowImportance
+
+	background := canvas.NewRectangle(theme.WarningColor())
+	banner = container.NewMax(background,
+		container.NewBorder(nil, nil, widget.NewIcon(theme.WarningIcon()), closeButton, bannerLabel))
+	banner.Hide()
+
+	return banner, bannerLabel
+}
+
+func ShowBanner(banner *fyne.Container, bannerLabel *widget.Label, message string) {
+	bannerLabel.SetText(message)
+	banner.Show()
+	banner.Refresh()
+}
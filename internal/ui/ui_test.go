@@ -1,7 +1,18 @@
 package ui
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"net"
+	"strings"
 	"testing"
+	"time"
+
+	"fyne.io/fyne/v2/test"
+	"github.com/michaeljsaenz/kview/internal/k8s"
 )
 
 var testingList []string
@@ -16,3 +27,327 @@ func TestGetListData(t *testing.T) {
 func TestSetupErrorUI(t *testing.T) {
 
 }
+
+func TestContextColor(t *testing.T) {
+	if ContextColor("staging") != ContextColor("staging") {
+		t.Errorf("Did not get expected result. Same context returned different colors")
+	}
+}
+
+func TestContextDisplay(t *testing.T) {
+	prefs := test.NewApp().Preferences()
+	contextName := "gke_my-project_us-central1-a_my-cluster"
+
+	if displayName := GetContextDisplayName(prefs, contextName); displayName != "us-central1-a/my-cluster" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", displayName, "us-central1-a/my-cluster")
+	}
+
+	SetContextDisplay(prefs, contextName, "prod", color.NRGBA{R: 255, G: 0, B: 16, A: 255})
+	if displayName := GetContextDisplayName(prefs, contextName); displayName != "prod" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", displayName, "prod")
+	}
+	if hex := colorToHex(GetContextColor(prefs, contextName)); hex != "#ff0010" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", hex, "#ff0010")
+	}
+
+	SetContextDisplay(prefs, contextName, "", nil)
+	if GetContextColor(prefs, contextName) != ContextColor(contextName) {
+		t.Errorf("Did not get expected result. Context color was not reset")
+	}
+}
+
+func TestLoader(t *testing.T) {
+	busy := make(chan bool, 4)
+	loader := NewLoader(func(b bool) { busy <- b })
+
+	// a slow load of the first selection must not overwrite the second selection
+	var applied []string
+	release := make(chan struct{})
+	done := make(chan struct{}, 2)
+	first := loader.Reset()
+	loader.Run(first, func(ctx context.Context) func() {
+		<-release
+		return func() { applied = append(applied, "first") }
+	})
+	second := loader.Reset()
+	if first.Err() == nil {
+		t.Errorf("Did not get expected result. First selection was not canceled")
+	}
+	loader.Run(second, func(ctx context.Context) func() {
+		return func() {
+			applied = append(applied, "second")
+			done <- struct{}{}
+		}
+	})
+	<-done
+	close(release)
+
+	if b := <-busy; !b {
+		t.Errorf("Did not get expected result. Got '%t', wanted '%t'", b, true)
+	}
+	if b := <-busy; b {
+		t.Errorf("Did not get expected result. Got '%t', wanted '%t'", b, false)
+	}
+	if strings.Join(applied, ",") != "second" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", strings.Join(applied, ","), "second")
+	}
+}
+
+func TestParseLogSince(t *testing.T) {
+	tests := []struct {
+		text      string
+		since     time.Duration
+		sinceTime time.Time
+	}{
+		{"", 0, time.Time{}},
+		{"15m", 15 * time.Minute, time.Time{}},
+		{"2d", 48 * time.Hour, time.Time{}},
+		{"2023-05-01T10:00:00Z", 0, time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)},
+		{"2023-05-01 10:00", 0, time.Date(2023, 5, 1, 10, 0, 0, 0, time.Local)},
+	}
+	for _, test := range tests {
+		since, sinceTime, err := parseLogSince(test.text)
+		if err != nil || since != test.since || !sinceTime.Equal(test.sinceTime) {
+			t.Errorf("Did not get expected result for '%s'. Got '%s %s', wanted '%s %s'", test.text, since, sinceTime,
+				test.since, test.sinceTime)
+		}
+	}
+	if _, _, err := parseLogSince("yesterday"); err == nil {
+		t.Errorf("Did not get expected result. Got no error for an invalid since")
+	}
+}
+
+func TestLogView(t *testing.T) {
+	test.NewApp()
+	v := NewLogView()
+	v.buffer = newLogBuffer(3)
+	v.SetText("a\nb")
+	v.AppendLines([]string{"c", "d", "e"})
+	if got := v.Text(); got != "c\nd\ne" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", got, "c\nd\ne")
+	}
+
+	// line numbers count the dropped lines, 4 and 5 are d and e
+	v.selectionAnchor, v.selectionEnd = 5, 4
+	if got := v.SelectedText(); got != "d\ne" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", got, "d\ne")
+	}
+	v.ClearSelection()
+	if got := v.SelectedText(); got != "c\nd\ne" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", got, "c\nd\ne")
+	}
+}
+
+func TestLogSearch(t *testing.T) {
+	test.NewApp()
+	v := NewLogView()
+	v.SetLines([]string{"start", "ERROR one", "a", "b", "c", "error two", "end"})
+
+	search, err := compileLogSearch("error", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.SetSearch(search, false, 0)
+	if count := v.MatchCount(); count != 2 {
+		t.Errorf("Did not get expected result. Got '%d', wanted '%d'", count, 2)
+	}
+	if position, _ := v.NextMatch(false); position != 2 {
+		t.Errorf("Did not get expected result. Got '%d', wanted '%d'", position, 2)
+	}
+
+	// only matching lines with one context line, like grep -C 1
+	v.SetSearch(search, true, 1)
+	v.AppendLines([]string{"x", "fatal error"})
+	want := "start\nERROR one\na\nc\nerror two\nend\nx\nfatal error"
+	if got := v.SelectedText(); got != want {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", got, want)
+	}
+
+	if search, _ := compileLogSearch("error", false, true); search.MatchString("ERROR one") {
+		t.Errorf("Did not get expected result. Match case matched a different case")
+	}
+	if _, err := compileLogSearch("(", true, false); err == nil {
+		t.Errorf("Did not get expected result. Got no error for an invalid regex")
+	}
+}
+
+func TestParseLogRecord(t *testing.T) {
+	record := parseLogRecord(`2024-01-01T10:00:00Z {"level":"ERROR","msg":"failed","user":"alice","req":{"id": 7}}`)
+	if !record.structured || record.level != "ERROR" || record.message != "failed" || record.time != "2024-01-01T10:00:00Z" {
+		t.Errorf("Did not get expected result. Got '%+v'", record)
+	}
+	if got := record.fields["req"]; got != `{"id":7}` {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", got, `{"id":7}`)
+	}
+
+	record = parseLogRecord(`ts=2024-01-01T10:00:00Z lvl=warn msg="disk almost full" used=91%`)
+	if !record.structured || record.level != "warn" || record.message != "disk almost full" || record.fields["used"] != "91%" {
+		t.Errorf("Did not get expected result. Got '%+v'", record)
+	}
+
+	if record := parseLogRecord("Starting server on :8080"); record.structured || record.message != "Starting server on :8080" {
+		t.Errorf("Did not get expected result. Got '%+v'", record)
+	}
+
+	// level=error also matches the normalized level of other level fields
+	filter := parseLogFilter("level=error user!=bob")
+	if !matchesLogFilter(parseLogRecord(`severity=FATAL user=alice`), filter) {
+		t.Errorf("Did not get expected result. Filter did not match a fatal line")
+	}
+	if matchesLogFilter(parseLogRecord(`level=error user=bob`), filter) {
+		t.Errorf("Did not get expected result. Filter matched an excluded user")
+	}
+}
+
+func TestGetLogExportSummary(t *testing.T) {
+	manifest := k8s.LogManifest{Pod: "web", Files: []k8s.LogManifestFile{{Name: "containers/app.log", Container: "app"},
+		{Container: "app", Previous: true, Error: "previous terminated container not found"}}}
+	wanted := "Exported 1 logs of pod web to web.zip\n\nNot complete:\napp (previous): previous terminated container not found"
+	if got := getLogExportSummary(manifest, "web.zip"); got != wanted {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", got, wanted)
+	}
+
+	if got := getLogFileName("web", "app", true, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)); got != "web-app-previous-20240102-030405.log" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", got, "web-app-previous-20240102-030405.log")
+	}
+}
+
+func TestGetContainerLabel(t *testing.T) {
+	label := getContainerLabel(k8s.PodContainer{Name: "migrate", Type: k8s.ContainerTypeInit, State: k8s.ContainerTerminated,
+		Reason: "Error", ExitCode: 1})
+	if label != "migrate [init] · terminated: Error (exit 1)" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", label, "migrate [init] · terminated: Error (exit 1)")
+	}
+	if label := getContainerLabel(k8s.PodContainer{Name: "app", Type: k8s.ContainerTypeRegular, State: k8s.ContainerRunning}); label != "app · running" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", label, "app · running")
+	}
+}
+
+func TestLogHistogram(t *testing.T) {
+	test.NewApp()
+	lines := []string{
+		"2024-01-01T10:00:00Z starting",
+		"2024-01-01T10:01:00Z ERROR connection refused",
+		`2024-01-01T10:02:00Z {"level":"error","msg":"retry failed"}`,
+		"2024-01-01T10:03:00Z ready",
+	}
+	h := NewLogHistogram()
+	var first, last int
+	h.OnZoom = func(zoomFirst int, zoomLast int) { first, last = zoomFirst, zoomLast }
+	h.SetLines(lines)
+	if !strings.HasSuffix(h.rangeLabel.Text, "4 lines, 2 errors") {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", h.rangeLabel.Text, "4 lines, 2 errors")
+	}
+
+	// the lines of the window are shown in the log view
+	h.zoom(time.Date(2024, 1, 1, 10, 0, 30, 0, time.UTC), time.Date(2024, 1, 1, 10, 2, 30, 0, time.UTC))
+	if first != 2 || last != 3 {
+		t.Errorf("Did not get expected result. Got '%d-%d', wanted '%d-%d'", first, last, 2, 3)
+	}
+	v := NewLogView()
+	v.SetLines(lines)
+	v.SetLineRange(first, last)
+	if got := v.SelectedText(); got != strings.Join(lines[1:3], "\n") {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", got, strings.Join(lines[1:3], "\n"))
+	}
+}
+
+func TestTerminalScreen(t *testing.T) {
+	s := newTerminalScreen(10, 3)
+	var reply string
+	s.reply = func(data []byte) { reply += string(data) }
+	s.write([]byte("hello\r\nwor"))
+	s.write([]byte("ld\x1b[1;31m!\x1b[0m\r\nthree\r\nfour"))
+	if got := s.text(); got != "hello\nworld!\nthree\nfour" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", got, "hello\nworld!\nthree\nfour")
+	}
+	if !s.lines[0][5].style.Bold || s.lines[0][4].style.Bold {
+		t.Errorf("Did not get expected result. Got '%v', wanted '%v'", s.lines[0][5].style.Bold, true)
+	}
+
+	// full screen programs draw on the alternate screen, the main screen is restored when they exit
+	s.write([]byte("\x1b[?1049h\x1b[2J\x1b[2;3Hvim\x1b[6n"))
+	if got := getTerminalLineText(s.lines[1]); got != "  vim" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", got, "  vim")
+	}
+	if reply != "\x1b[2;6R" {
+		t.Errorf("Did not get expected result. Got '%q', wanted '%q'", reply, "\x1b[2;6R")
+	}
+	s.write([]byte("\x1b[?1049l"))
+	if got := s.text(); got != "hello\nworld!\nthree\nfour" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", got, "hello\nworld!\nthree\nfour")
+	}
+}
+
+func TestExecOutput(t *testing.T) {
+	var lines []string
+	o := newExecOutput(func(line string) { lines = append(lines, line) }, "\x1b[31m")
+	o.Write([]byte("first\r\nsec"))
+	o.Write([]byte("ond\nlast"))
+	o.Close()
+	want := []string{"\x1b[31mfirst\x1b[0m", "\x1b[31msecond\x1b[0m", "\x1b[31mlast\x1b[0m"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("Did not get expected result. Got '%q', wanted '%q'", lines, want)
+	}
+
+	lines = nil
+	o = newExecOutput(func(line string) { lines = append(lines, line) }, "")
+	o.Write(bytes.Repeat([]byte("x"), maxExecLineLength+10))
+	if len(lines) != 1 || len(o.partial) != 10 {
+		t.Errorf("Did not get expected result. Got %d lines and %d pending bytes, wanted 1 and 10", len(lines), len(o.partial))
+	}
+
+	if status := getExecStatus(fmt.Errorf("stopped: %w", context.Canceled), 1520*time.Millisecond); status != "Stopped after 1.5s" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", status, "Stopped after 1.5s")
+	}
+}
+
+func TestFormatFanOutGroups(t *testing.T) {
+	results := []k8s.FanOutResult{
+		{Target: k8s.FanOutTarget{Pod: "web-1", Container: "app"}, Output: "abc\n"},
+		{Target: k8s.FanOutTarget{Pod: "web-2", Container: "app"}, Output: "def\n"},
+		{Target: k8s.FanOutTarget{Pod: "web-3", Container: "app"}, Output: "abc\n"},
+	}
+	lines := formatFanOutGroups(k8s.GroupFanOutResults(results))
+	want := []string{"\x1b[1;32m▶ 2 pods  exit 0: web-1, web-3\x1b[0m", "abc", "",
+		"\x1b[1;33m▶ 1 pod  exit 0  outlier: web-2\x1b[0m", "def", ""}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("Did not get expected result. Got '%q', wanted '%q'", lines, want)
+	}
+
+	status := getFanOutStatus(results, 4)
+	if status != "3 of 4 pods done: 3 exit 0, 0 failed, 2 distinct outputs" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", status, "3 of 4 pods done: 3 exit 0, 0 failed, 2 distinct outputs")
+	}
+}
+
+func TestLogTabPendingCap(t *testing.T) {
+	tab := &LogTab{paused: true}
+	for i := 0; i < logViewCapacity+5; i++ {
+		tab.addLine(fmt.Sprint(i))
+	}
+	if len(tab.pending) != logViewCapacity || tab.dropped != 5 || tab.pending[0] != "5" {
+		t.Errorf("Did not get expected result. Got '%d' lines, '%d' dropped, first '%s', wanted '%d' lines, '%d' dropped, first '%s'",
+			len(tab.pending), tab.dropped, tab.pending[0], logViewCapacity, 5, "5")
+	}
+}
+
+func TestStatusBarDialogBurst(t *testing.T) {
+	w := test.NewApp().NewWindow("")
+	bar := NewStatusBar(w)
+	err := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection timed out")}
+	bar.SetError("List pods", err, nil)
+	bar.SetError("Get pod web-1", err, nil)
+	if dialogs := len(w.Canvas().Overlays().List()); dialogs != 1 {
+		t.Errorf("Did not get expected result. Got '%d' dialogs, wanted '%d'", dialogs, 1)
+	}
+	if bar.label.Text != "Cluster Unreachable - Get pod web-1: "+err.Error() {
+		t.Errorf("Did not get expected result. Got '%s', wanted the last error", bar.label.Text)
+	}
+
+	bar.Clear()
+	bar.SetError("List pods", err, nil)
+	if dialogs := len(w.Canvas().Overlays().List()); dialogs != 2 {
+		t.Errorf("Did not get expected result. Got '%d' dialogs, wanted '%d'", dialogs, 2)
+	}
+}
@@ -0,0 +1,191 @@
+package ui
+
+import (
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"github.com/michaeljsaenz/kview/internal/k8s"
+)
+
+// Workspace shows one tab per cluster session, so several clusters can be viewed side by side
+type Workspace struct {
+	Content fyne.CanvasObject
+
+	app          fyne.App
+	win          fyne.Window
+	tabs         *container.DocTabs
+	openDropdown *ContextDropdown
+	statusBar    *StatusBar
+	sessionMutex sync.Mutex
+	sessions     map[*container.TabItem]*Session
+
+	// the kubeconfig watch updates the contexts, contextsMutex guards them
+	contextsMutex     sync.Mutex
+	contexts          []string
+	kubeconfigContext string
+	// loading the contexts failed and was reported, reported again only after a retry
+	contextsFailed bool
+}
+
+// NewWorkspace opens a first session on the kubeconfig current-context
+func NewWorkspace(app fyne.App, win fyne.Window) *Workspace {
+	w := &Workspace{app: app, win: win, sessions: make(map[*container.TabItem]*Session)}
+	w.kubeconfigContext, _ = k8s.GetCurrentContext()
+	w.statusBar = NewStatusBar(win)
+
+	contexts, err := k8s.GetContexts()
+	w.setContextsError(err)
+	w.contexts = contexts
+
+	w.tabs = container.NewDocTabs()
+	w.tabs.CloseIntercept = func(item *container.TabItem) {
+		// keep at least one session open
+		if len(w.tabs.Items) == 1 {
+			return
+		}
+		w.sessionMutex.Lock()
+		if session, ok := w.sessions[item]; ok {
+			session.Close()
+		}
+		delete(w.sessions, item)
+		w.sessionMutex.Unlock()
+		w.tabs.Remove(item)
+	}
+
+	w.openDropdown = CreateContextDropdown(app.Preferences(), contexts, func(selectedContext string) {
+		go w.OpenSession(selectedContext)
+	})
+	w.openDropdown.PlaceHolder = "Open cluster context..."
+
+	w.addSession(NewSession(app, win, w.kubeconfigContext, contexts, true))
+
+	toolbar := container.NewBorder(nil, nil, nil, w.openDropdown.Select)
+	w.Content = container.NewBorder(container.NewVBox(toolbar, w.statusBar.Content), nil, nil, nil, w.tabs)
+
+	return w
+}
+
+// ShowError shows an error that doesn't belong to a session in the workspace status bar
+func (w *Workspace) ShowError(action string, err error) {
+	w.statusBar.SetError(action, err, nil)
+}
+
+// report a failed context list once until it loads again or is retried
+func (w *Workspace) setContextsError(err error) {
+	w.contextsMutex.Lock()
+	defer w.contextsMutex.Unlock()
+	if err == nil {
+		if w.contextsFailed {
+			w.contextsFailed = false
+			w.statusBar.Clear()
+		}
+		return
+	}
+	if !w.contextsFailed {
+		w.contextsFailed = true
+		w.statusBar.SetError("Loading cluster contexts", err, func() {
+			w.contextsMutex.Lock()
+			w.contextsFailed = false
+			w.contextsMutex.Unlock()
+			w.OnKubeconfigChange()
+		})
+	}
+}
+
+// OpenSession selects the session for contextName, creating it when not open yet
+func (w *Workspace) OpenSession(contextName string) {
+	w.sessionMutex.Lock()
+	for item, session := range w.sessions {
+		if session.Context() == contextName {
+			w.sessionMutex.Unlock()
+			w.tabs.Select(item)
+			return
+		}
+	}
+	w.sessionMutex.Unlock()
+
+	w.addSession(NewSession(w.app, w.win, contextName, w.getContexts(), false))
+}
+
+func (w *Workspace) getContexts() []string {
+	w.contextsMutex.Lock()
+	defer w.contextsMutex.Unlock()
+	return w.contexts
+}
+
+func (w *Workspace) addSession(session *Session) {
+	item := container.NewTabItemWithIcon(GetContextDisplayName(w.app.Preferences(), session.Context()), theme.ComputerIcon(), session.Content)
+	session.OnContextChanged = func(s *Session) {
+		item.Text = GetContextDisplayName(w.app.Preferences(), s.Context())
+		w.tabs.Refresh()
+	}
+	session.OnDisplayChanged = w.refreshContextDisplay
+
+	w.sessionMutex.Lock()
+	w.sessions[item] = session
+	w.sessionMutex.Unlock()
+
+	w.tabs.Append(item)
+	w.tabs.Select(item)
+}
+
+// OnKubeconfigChange refreshes the context lists and moves the following session
+// to the new kubeconfig current-context
+func (w *Workspace) OnKubeconfigChange() {
+	resetContextServers()
+	contexts, err := k8s.GetContexts()
+	w.setContextsError(err)
+	if err == nil {
+		w.contextsMutex.Lock()
+		w.contexts = contexts
+		w.contextsMutex.Unlock()
+		w.openDropdown.SetContexts(contexts)
+	}
+
+	w.sessionMutex.Lock()
+	var sessions []*Session
+	for _, session := range w.sessions {
+		sessions = append(sessions, session)
+	}
+	w.sessionMutex.Unlock()
+
+	if err == nil {
+		for _, session := range sessions {
+			session.SetContexts(contexts)
+		}
+	}
+
+	newKubeconfigContext, err := k8s.GetCurrentContext()
+	if err != nil {
+		return
+	}
+	w.contextsMutex.Lock()
+	changed := newKubeconfigContext != w.kubeconfigContext
+	w.kubeconfigContext = newKubeconfigContext
+	w.contextsMutex.Unlock()
+	if !changed {
+		return
+	}
+	for _, session := range sessions {
+		if session.Following {
+			session.SwitchContext(newKubeconfigContext, "kubeconfig current-context changed")
+		}
+	}
+}
+
+// apply edited context aliases and colors to every session, tab and context list
+func (w *Workspace) refreshContextDisplay() {
+	contexts := w.getContexts()
+	w.openDropdown.SetContexts(contexts)
+
+	w.sessionMutex.Lock()
+	defer w.sessionMutex.Unlock()
+	for item, session := range w.sessions {
+		session.SetContexts(contexts)
+		session.RefreshContextDisplay()
+		item.Text = GetContextDisplayName(w.app.Preferences(), session.Context())
+	}
+	w.tabs.Refresh()
+}
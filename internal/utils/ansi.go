@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// ANSIColor is a terminal color, the default color when Set is false
+type ANSIColor struct {
+	Set bool
+	// index in the 256 color palette, 0-7 are the basic colors and 8-15 their bright variants
+	Index int
+	// true color, used instead of Index when RGB is set
+	RGB     bool
+	R, G, B uint8
+}
+
+// ANSIStyle is the style set by SGR escape sequences
+type ANSIStyle struct {
+	Foreground ANSIColor
+	Background ANSIColor
+	Bold       bool
+	Faint      bool
+	Italic     bool
+	Underline  bool
+	Inverse    bool
+}
+
+// ANSISegment is text with one style
+type ANSISegment struct {
+	Text  string
+	Style ANSIStyle
+}
+
+// basic colors and their bright variants, like xterm
+var ansiBasicColors = []color.NRGBA{
+	{R: 0, G: 0, B: 0, A: 255}, {R: 205, G: 49, B: 49, A: 255}, {R: 13, G: 188, B: 121, A: 255},
+	{R: 229, G: 229, B: 16, A: 255}, {R: 36, G: 114, B: 200, A: 255}, {R: 188, G: 63, B: 188, A: 255},
+	{R: 17, G: 168, B: 205, A: 255}, {R: 229, G: 229, B: 229, A: 255},
+	{R: 102, G: 102, B: 102, A: 255}, {R: 241, G: 76, B: 76, A: 255}, {R: 35, G: 209, B: 139, A: 255},
+	{R: 245, G: 245, B: 67, A: 255}, {R: 59, G: 142, B: 234, A: 255}, {R: 214, G: 112, B: 214, A: 255},
+	{R: 41, G: 184, B: 219, A: 255}, {R: 255, G: 255, B: 255, A: 255},
+}
+
+// NRGBA returns the color of a set ANSI color
+func (c ANSIColor) NRGBA() color.NRGBA {
+	switch {
+	case c.RGB:
+		return color.NRGBA{R: c.R, G: c.G, B: c.B, A: 255}
+	case c.Index < 16:
+		return ansiBasicColors[c.Index]
+	case c.Index < 232:
+		// 6x6x6 color cube
+		cube := c.Index - 16
+		level := func(n int) uint8 {
+			if n == 0 {
+				return 0
+			}
+			return uint8(55 + n*40)
+		}
+		return color.NRGBA{R: level(cube / 36), G: level(cube / 6 % 6), B: level(cube % 6), A: 255}
+	default:
+		// grayscale ramp
+		gray := uint8(8 + (c.Index-232)*10)
+		return color.NRGBA{R: gray, G: gray, B: gray, A: 255}
+	}
+}
+
+// ParseANSI splits text into styled segments. SGR sequences (colors, bold, underline, ...) set the
+// style of the text after them, all other escape sequences are removed.
+func ParseANSI(text string) []ANSISegment {
+	var segments []ANSISegment
+	var style ANSIStyle
+	var segment strings.Builder
+	addSegment := func() {
+		if segment.Len() > 0 {
+			segments = append(segments, ANSISegment{Text: segment.String(), Style: style})
+			segment.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		escape := strings.IndexByte(text[i:], '\x1b')
+		if escape < 0 {
+			segment.WriteString(text[i:])
+			break
+		}
+		segment.WriteString(text[i : i+escape])
+		i += escape + 1
+		if i >= len(text) {
+			break
+		}
+
+		switch text[i] {
+		case '[':
+			// control sequence: parameters, intermediate bytes and a final byte
+			end := i + 1
+			for end < len(text) && (text[end] < 0x40 || text[end] > 0x7e) {
+				end++
+			}
+			if end >= len(text) {
+				i = len(text)
+				continue
+			}
+			if text[end] == 'm' {
+				addSegment()
+				style = ApplySGR(style, text[i+1:end])
+			}
+			i = end + 1
+		case ']':
+			// operating system command, ends with BEL or ESC \
+			end := i + 1
+			for end < len(text) && text[end] != '\a' && !(text[end] == '\x1b' && end+1 < len(text) && text[end+1] == '\\') {
+				end++
+			}
+			if end < len(text) && text[end] == '\x1b' {
+				end++
+			}
+			i = end + 1
+		default:
+			// two character sequence
+			i++
+		}
+	}
+	addSegment()
+	return segments
+}
+
+// ApplySGR applies the parameters of an SGR sequence (the text between "ESC [" and "m") to a style
+func ApplySGR(style ANSIStyle, parameters string) ANSIStyle {
+	// colon separated sub-parameters (38:5:196) are read like semicolons
+	fields := strings.FieldsFunc(parameters, func(r rune) bool { return r == ';' || r == ':' })
+	if len(fields) == 0 {
+		return ANSIStyle{}
+	}
+	codes := make([]int, len(fields))
+	for i, field := range fields {
+		codes[i], _ = strconv.Atoi(field)
+	}
+
+	for i := 0; i < len(codes); i++ {
+		switch code := codes[i]; {
+		case code == 0:
+			style = ANSIStyle{}
+		case code == 1:
+			style.Bold = true
+		case code == 2:
+			style.Faint = true
+		case code == 3:
+			style.Italic = true
+		case code == 4:
+			style.Underline = true
+		case code == 7:
+			style.Inverse = true
+		case code == 22:
+			style.Bold, style.Faint = false, false
+		case code == 23:
+			style.Italic = false
+		case code == 24:
+			style.Underline = false
+		case code == 27:
+			style.Inverse = false
+		case code >= 30 && code <= 37:
+			style.Foreground = ANSIColor{Set: true, Index: code - 30}
+		case code == 38:
+			style.Foreground, i = parseExtendedColor(codes, i)
+		case code == 39:
+			style.Foreground = ANSIColor{}
+		case code >= 40 && code <= 47:
+			style.Background = ANSIColor{Set: true, Index: code - 40}
+		case code == 48:
+			style.Background, i = parseExtendedColor(codes, i)
+		case code == 49:
+			style.Background = ANSIColor{}
+		case code >= 90 && code <= 97:
+			style.Foreground = ANSIColor{Set: true, Index: code - 90 + 8}
+		case code >= 100 && code <= 107:
+			style.Background = ANSIColor{Set: true, Index: code - 100 + 8}
+		}
+	}
+	return style
+}
+
+// parse 38;5;n and 38;2;r;g;b colors starting at codes[i], returns the index of the last code used
+func parseExtendedColor(codes []int, i int) (ANSIColor, int) {
+	if i+2 < len(codes) && codes[i+1] == 5 {
+		return ANSIColor{Set: true, Index: clampColor(codes[i+2])}, i + 2
+	}
+	if i+4 < len(codes) && codes[i+1] == 2 {
+		return ANSIColor{Set: true, RGB: true, R: uint8(clampColor(codes[i+2])), G: uint8(clampColor(codes[i+3])),
+			B: uint8(clampColor(codes[i+4]))}, i + 4
+	}
+	return ANSIColor{}, len(codes)
+}
+
+func clampColor(value int) int {
+	if value < 0 {
+		return 0
+	}
+	if value > 255 {
+		return 255
+	}
+	return value
+}
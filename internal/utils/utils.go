@@ -3,7 +3,6 @@ package utils
 import (
 	"bytes"
 	"fmt"
-	"regexp"
 	"strings"
 )
 
@@ -15,27 +14,34 @@ func ConvertMapToString(m map[string]string) string {
 	return b.String()
 }
 
-// check for string(error) in slice
-func CheckForError(slice []string) (string, bool) {
-	if slice != nil {
-		checkValue := slice[0]
-		listOfErrors := []string{"i/o timeout", "context deadline exceeded", "connection refused", "Bad Request"}
-		for _, error := range listOfErrors {
-			if strings.Contains(checkValue, error) {
-				return "Error: " + checkValue, true
-			}
-		}
-	}
-	return "", false
-}
-
 // return pointer to int64
 func CreateInt64(num int64) *int64 {
 	return &num
 }
 
+// remove terminal escape sequences like colors, see ParseANSI
 func RemoveANSIEscapeCodes(input string) string {
-	regex := regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
-	clean := regex.ReplaceAllString(input, "")
-	return clean
+	if !strings.Contains(input, "\x1b") {
+		return input
+	}
+	var clean strings.Builder
+	for _, segment := range ParseANSI(input) {
+		clean.WriteString(segment.Text)
+	}
+	return clean.String()
+}
+
+// format a size in bytes with a binary unit, e.g. 1.5 MiB
+func FormatBytes(size int64) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size) / 1024
+	units := []string{"KiB", "MiB", "GiB", "TiB"}
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
 }
@@ -14,23 +14,31 @@ func TestConvertMapToString(t *testing.T) {
 	}
 }
 
-func TestCheckForError(t *testing.T) {
-	testForErrorSlice := []string{"i/o timeout", "context deadline exceeded", "connection refused", "...no error found...", "Bad Request"}
-	for _, error := range testForErrorSlice {
-		expectedTestValueString := "Error: " + error + " (validate cluster access and restart)"
-		errorSlice := []string{error}
-		if error == "...no error found..." {
-			returnedValueString, returnedBool := CheckForError(errorSlice)
-			if (returnedBool != false) || (returnedValueString != "") {
-				t.Errorf("Did not get expected result. Got '%s' and '%v', wanted '%s' and '%v'",
-					returnedValueString, returnedBool, expectedTestValueString, false)
-			}
-		} else {
-			returnedValueString, returnedBool := CheckForError(errorSlice)
-			if (returnedBool != true) || (returnedValueString != expectedTestValueString) {
-				t.Errorf("Did not get expected result. Got '%s' and '%v', wanted '%s' and '%v'",
-					returnedValueString, returnedBool, expectedTestValueString, true)
-			}
+func TestParseANSI(t *testing.T) {
+	segments := ParseANSI("plain \x1b[1;31merror\x1b[0m \x1b[38;5;208mwarn\x1b[39;4m done\x1b]0;title\a\x1b[K")
+	if len(segments) != 5 {
+		t.Fatalf("Did not get expected result. Got '%d' segments, wanted '%d'", len(segments), 5)
+	}
+	if text, style := segments[1].Text, segments[1].Style; text != "error" || !style.Bold || style.Foreground.Index != 1 {
+		t.Errorf("Did not get expected result. Got '%s %+v'", text, style)
+	}
+	if text, style := segments[3].Text, segments[3].Style; text != "warn" || style.Foreground.Index != 208 {
+		t.Errorf("Did not get expected result. Got '%s %+v'", text, style)
+	}
+	if style := segments[4].Style; style.Foreground.Set || !style.Underline {
+		t.Errorf("Did not get expected result. Got '%+v'", style)
+	}
+
+	clean := RemoveANSIEscapeCodes("\x1b[32mok\x1b[0m\x1b]8;;http://x\x1b\\ link")
+	if clean != "ok link" {
+		t.Errorf("Did not get expected result. Got '%s', wanted '%s'", clean, "ok link")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for size, wanted := range map[int64]string{512: "512 B", 1536: "1.5 KiB", 5 * 1024 * 1024 * 1024: "5.0 GiB"} {
+		if got := FormatBytes(size); got != wanted {
+			t.Errorf("Did not get expected result. Got '%s', wanted '%s'", got, wanted)
 		}
 	}
 }